@@ -0,0 +1,30 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"github.com/maestro-milagro/Post_Service_PB/internal/service"
+	"github.com/maestro-milagro/Post_Service_PB/internal/service/backfill"
+	"log/slog"
+)
+
+// runBackfillKeys implements the one-off "backfill-keys" subcommand.
+func runBackfillKeys(ctx context.Context, log *slog.Logger, store backfill.Store, blobs service.BlobStore, args []string) error {
+	fs := flag.NewFlagSet("backfill-keys", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "only report which posts would be moved")
+	batchSize := fs.Int("batch-size", 100, "posts fetched per query")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	stats, err := backfill.NewKeyBackfiller(log, store, blobs, *batchSize, *dryRun).Run(ctx)
+
+	log.Info("key backfill finished",
+		slog.Bool("dry_run", *dryRun),
+		slog.Int("migrated", stats.Migrated),
+		slog.Int("skipped", stats.Skipped),
+		slog.Int("objects_deleted", stats.ObjectsDeleted),
+	)
+
+	return err
+}
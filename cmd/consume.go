@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"github.com/maestro-milagro/Post_Service_PB/internal/config"
+	"github.com/maestro-milagro/Post_Service_PB/internal/lib/sl"
+	"github.com/maestro-milagro/Post_Service_PB/internal/models"
+	"github.com/maestro-milagro/Post_Service_PB/internal/service/kafka"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// runConsumer implements the "consume" subcommand: it fans post-created events
+// out into followers' feeds until the process is signalled to stop.
+func runConsumer(log *slog.Logger, cfg *config.Config, handler kafka.PostHandler) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	consumer, err := kafka.NewConsumer(log,
+		[]string{cfg.KafkaBootstrapServer},
+		cfg.KafkaConsumerGroup,
+		[]string{models.PostsTopic},
+		handler,
+	)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := consumer.Close(); err != nil {
+			log.Error("failed to close consumer", sl.Err(err))
+		}
+	}()
+
+	log.Info("starting feed consumer", slog.String("group", cfg.KafkaConsumerGroup))
+
+	if err := consumer.Run(ctx); err != nil {
+		return err
+	}
+
+	log.Info("feed consumer stopped")
+
+	return nil
+}
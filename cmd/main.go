@@ -2,26 +2,55 @@ package main
 
 import (
 	"context"
+	"expvar"
+	"fmt"
 	"github.com/go-chi/chi/v5"
 	"github.com/maestro-milagro/Post_Service_PB/internal/config"
+	"github.com/maestro-milagro/Post_Service_PB/internal/http-server/handlers/create_token"
 	"github.com/maestro-milagro/Post_Service_PB/internal/http-server/handlers/delete"
+	"github.com/maestro-milagro/Post_Service_PB/internal/http-server/handlers/diff"
+	"github.com/maestro-milagro/Post_Service_PB/internal/http-server/handlers/edit"
+	"github.com/maestro-milagro/Post_Service_PB/internal/http-server/handlers/feed"
+	"github.com/maestro-milagro/Post_Service_PB/internal/http-server/handlers/fork"
 	"github.com/maestro-milagro/Post_Service_PB/internal/http-server/handlers/get_all"
 	"github.com/maestro-milagro/Post_Service_PB/internal/http-server/handlers/get_id"
+	"github.com/maestro-milagro/Post_Service_PB/internal/http-server/handlers/html"
 	"github.com/maestro-milagro/Post_Service_PB/internal/http-server/handlers/post"
+	"github.com/maestro-milagro/Post_Service_PB/internal/http-server/handlers/raw"
+	"github.com/maestro-milagro/Post_Service_PB/internal/http-server/handlers/restore"
+	"github.com/maestro-milagro/Post_Service_PB/internal/http-server/handlers/revision"
+	"github.com/maestro-milagro/Post_Service_PB/internal/http-server/handlers/revisions"
+	"github.com/maestro-milagro/Post_Service_PB/internal/http-server/handlers/revoke"
+	"github.com/maestro-milagro/Post_Service_PB/internal/http-server/handlers/revoke_token"
 	"github.com/maestro-milagro/Post_Service_PB/internal/http-server/handlers/subscribe"
+	apiTokens "github.com/maestro-milagro/Post_Service_PB/internal/http-server/handlers/tokens"
+	"github.com/maestro-milagro/Post_Service_PB/internal/http-server/handlers/unsubscribe"
+	mwAuth "github.com/maestro-milagro/Post_Service_PB/internal/http-server/middleware/auth"
+	libAuth "github.com/maestro-milagro/Post_Service_PB/internal/lib/auth"
+	libDiff "github.com/maestro-milagro/Post_Service_PB/internal/lib/diff"
+	"github.com/maestro-milagro/Post_Service_PB/internal/lib/highlight"
+	"github.com/maestro-milagro/Post_Service_PB/internal/lib/jwt"
 	"github.com/maestro-milagro/Post_Service_PB/internal/lib/sl"
+	"github.com/maestro-milagro/Post_Service_PB/internal/models"
 	"github.com/maestro-milagro/Post_Service_PB/internal/service"
 	"github.com/maestro-milagro/Post_Service_PB/internal/service/aws"
+	"github.com/maestro-milagro/Post_Service_PB/internal/service/envelope"
 	"github.com/maestro-milagro/Post_Service_PB/internal/service/kafka"
+	"github.com/maestro-milagro/Post_Service_PB/internal/service/localfs"
+	"github.com/maestro-milagro/Post_Service_PB/internal/service/outbox"
+	"github.com/maestro-milagro/Post_Service_PB/internal/service/reaper"
+	"github.com/maestro-milagro/Post_Service_PB/internal/service/revocation"
+	"github.com/maestro-milagro/Post_Service_PB/internal/service/tokens"
 	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
 
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/maestro-milagro/Post_Service_PB/internal/storage/postgres"
+	"github.com/maestro-milagro/Post_Service_PB/internal/storage/postgres/migrator"
+	"github.com/maestro-milagro/Post_Service_PB/migrations"
 )
 
 const (
@@ -49,6 +78,8 @@ func main() {
 		Password: cfg.Password,
 		DBName:   cfg.DBname,
 		SSLMode:  cfg.SSLmode,
+
+		SlugLength: cfg.SlugLength,
 	}
 
 	storage, err := postgres.New(dbConf)
@@ -56,14 +87,15 @@ func main() {
 		log.Error("failed to init storage", sl.Err(err))
 		os.Exit(1)
 	}
+	defer storage.Close()
 
-	router := chi.NewRouter()
+	dbMigrator, err := migrator.New(log, storage.DB(), migrations.FS)
+	if err != nil {
+		log.Error("failed to load migrations", sl.Err(err))
+		os.Exit(1)
+	}
 
-	router.Use(middleware.RequestID)
-	router.Use(middleware.Logger)
-	//	router.Use(mwLogger.New(log))
-	router.Use(middleware.Recoverer)
-	router.Use(middleware.URLFormat)
+	passwordGate := service.NewPasswordGate(log, cfg.PostPassword.MaxAttempts, cfg.PostPassword.Window)
 
 	servicePB := service.New(log,
 		storage,
@@ -72,32 +104,229 @@ func main() {
 		storage,
 		storage,
 		storage,
+		storage,
+		storage,
+		storage,
+		storage,
+		storage,
+		storage,
+		storage,
+		passwordGate,
 	)
 
-	awsService := aws.New(log)
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate":
+			if err := runMigrate(context.Background(), log, dbMigrator, os.Args[2:]); err != nil {
+				log.Error("migrate failed", sl.Err(err))
+				os.Exit(1)
+			}
+		case "backfill-keys":
+			if err := runBackfillKeys(context.Background(), log, storage, mustBlobStore(log, cfg.BlobStore), os.Args[2:]); err != nil {
+				log.Error("key backfill failed", sl.Err(err))
+				os.Exit(1)
+			}
+		case "rotate-keys":
+			if err := runRotateKeys(context.Background(), log, storage, mustKeyring(log, cfg.Encryption), os.Args[2:]); err != nil {
+				log.Error("key rotation failed", sl.Err(err))
+				os.Exit(1)
+			}
+		case "consume":
+			if err := runConsumer(log, cfg, servicePB); err != nil {
+				log.Error("consumer failed", sl.Err(err))
+				os.Exit(1)
+			}
+		default:
+			log.Error("unknown command", slog.String("command", os.Args[1]))
+			os.Exit(2)
+		}
+		return
+	}
+
+	// Хранилище и ключи нужны только серверу и командам, которые читают объекты
+	blobStore := mustBlobStore(log, cfg.BlobStore)
+	keyring := mustKeyring(log, cfg.Encryption)
+	content := service.NewContent(blobStore, keyring)
+
+	if cfg.MigrateOnStart {
+		applied, err := dbMigrator.Up(context.Background())
+		if err != nil {
+			log.Error("failed to apply migrations", sl.Err(err))
+			os.Exit(1)
+		}
+		log.Info("migrations applied", slog.Int("count", applied))
+	}
+
+	verifier, err := jwt.NewVerifier(jwt.Config{
+		Secret:   cfg.Secret,
+		JWKSFile: cfg.JWT.JWKSFile,
+		Issuer:   cfg.JWT.Issuer,
+		Audience: cfg.JWT.Audience,
+		Leeway:   cfg.JWT.Leeway,
+	})
+	if err != nil {
+		log.Error("failed to init token verifier", sl.Err(err))
+		os.Exit(1)
+	}
+
+	revocations := revocation.New(log, revocation.Config{
+		RefreshInterval: cfg.Revocation.RefreshInterval,
+		MaxTokenTTL:     cfg.Revocation.MaxTokenTTL,
+		Leeway:          cfg.JWT.Leeway,
+	}, storage)
+	if err := revocations.Load(context.Background()); err != nil {
+		log.Error("failed to load token revocations", sl.Err(err))
+		os.Exit(1)
+	}
+
+	router := chi.NewRouter()
+
+	router.Use(middleware.RequestID)
+	router.Use(middleware.Logger)
+	//	router.Use(mwLogger.New(log))
+	router.Use(middleware.Recoverer)
+	router.Use(middleware.URLFormat)
+
+	kafkaProd, err := kafka.New(log, []string{cfg.KafkaBootstrapServer})
+	if err != nil {
+		log.Error("failed to init kafka producer", sl.Err(err))
+		os.Exit(1)
+	}
+
+	relay := outbox.New(log, outbox.Config{
+		PollInterval: cfg.Outbox.PollInterval,
+		BatchSize:    cfg.Outbox.BatchSize,
+		Lease:        cfg.Outbox.Lease,
+		MinBackoff:   cfg.Outbox.MinBackoff,
+		MaxBackoff:   cfg.Outbox.MaxBackoff,
+		Retention:    cfg.Outbox.Retention,
+	}, storage, kafkaProd)
+
+	relayCtx, stopRelay := context.WithCancel(context.Background())
+	relayDone := make(chan struct{})
+	go func() {
+		defer close(relayDone)
+		relay.Run(relayCtx)
+	}()
+
+	postReaper := reaper.New(log, storage, blobStore, cfg.Reaper.Interval, cfg.Reaper.BatchSize)
+
+	reaperCtx, stopReaper := context.WithCancel(context.Background())
+	reaperDone := make(chan struct{})
+	go func() {
+		defer close(reaperDone)
+		postReaper.Run(reaperCtx)
+	}()
+
+	revocationsCtx, stopRevocations := context.WithCancel(context.Background())
+	revocationsDone := make(chan struct{})
+	go func() {
+		defer close(revocationsDone)
+		revocations.Run(revocationsCtx)
+	}()
+
+	tokenService := tokens.New(log, storage)
+
+	// Личные API-токены узнаём по префиксу, всё остальное считаем JWT
+	authenticator := libAuth.Prefixed{
+		Prefix:   tokens.Prefix,
+		Tokens:   tokenService,
+		Fallback: libAuth.NewJWT(verifier, revocations),
+	}
+	renderer := highlight.New(cfg.Highlight.Style, cfg.Highlight.CacheSize)
+
+	// Все методы ниже требуют токен: в заголовке Authorization или, по-старому, в теле запроса
+	router.Group(func(router chi.Router) {
+		router.Use(mwAuth.New(log, authenticator))
+
+		router.Group(func(router chi.Router) {
+			router.Use(mwAuth.RequireScope(log, models.ScopeRead))
+
+			router.Get("/feed", feed.New(log, servicePB))
+
+			// TODO: Метод на вывод всех постов
+			router.Get("/get_all", get_all.New(log, servicePB))
+
+			// TODO: Метод на вывод определенного поста
+			router.Get("/get_id/id={id}", get_id.New(log, cfg.Bucket, cfg.MaxInlineSize, content, servicePB))
+
+			router.Get("/get_id/id={id}/raw", raw.New(log, cfg.Bucket, cfg.TransferTimeout, content, servicePB))
+
+			router.Get("/get_id/id={id}/html", html.New(log, cfg.Bucket, cfg.Highlight.MaxSize, content, renderer, servicePB))
+
+			router.Get("/p/{slug}", get_id.New(log, cfg.Bucket, cfg.MaxInlineSize, content, servicePB))
+
+			router.Get("/p/{slug}/raw", raw.New(log, cfg.Bucket, cfg.TransferTimeout, content, servicePB))
+
+			router.Get("/p/{slug}/html", html.New(log, cfg.Bucket, cfg.Highlight.MaxSize, content, renderer, servicePB))
+
+			router.Get("/posts/{id}/revisions", revisions.New(log, servicePB))
+
+			router.Get("/posts/{id}/revisions/{n}", revision.New(log, cfg.Bucket, cfg.MaxInlineSize, content, servicePB))
+
+			router.Get("/posts/{id}/diff", diff.New(log, cfg.Bucket, cfg.Diff.MaxSize, libDiff.Limits{
+				MaxLines: cfg.Diff.MaxLines,
+				MaxEdits: cfg.Diff.MaxEdits,
+			}, content, servicePB))
+		})
+
+		router.Group(func(router chi.Router) {
+			router.Use(mwAuth.RequireScope(log, models.ScopeWrite))
+
+			// TODO: Метод на пост и оповещение об этом подписчиков
+			router.Post("/post", post.New(log,
+				cfg.Bucket,
+				cfg.MaxUploadSize,
+				cfg.MaxPostTTL,
+				cfg.TransferTimeout,
+				servicePB,
+				content,
+			))
+
+			router.Post("/p/{slug}/fork", fork.New(log, cfg.Bucket, content, servicePB))
+
+			router.Put("/posts/{id}", edit.New(log,
+				cfg.Bucket,
+				cfg.MaxUploadSize,
+				cfg.TransferTimeout,
+				servicePB,
+				content,
+			))
+
+			router.Post("/posts/{id}/revisions/{n}/restore", restore.New(log, servicePB))
 
-	kafkaProd := kafka.New(log, []string{cfg.KafkaBootstrapServer})
+			router.Post("/posts/{id}/fork", fork.New(log, cfg.Bucket, content, servicePB))
 
-	// TODO: Метод на подписку
-	router.Post("/subscribe", subscribe.New(log, servicePB))
+			// Подписчиком всегда становится владелец токена
+			router.Post("/subscribe", subscribe.New(log, servicePB))
 
-	// TODO: Метод на пост и оповещение об этом подписчиков
-	router.Post("/post", post.New(log,
-		cfg.Bucket,
-		cfg.Secret,
-		servicePB,
-		awsService,
-		kafkaProd,
-	))
+			router.Delete("/subscriptions/{id}", unsubscribe.New(log, servicePB))
+		})
 
-	// TODO: Метод на вывод всех постов
-	router.Get("/get_all", get_all.New(log, cfg.Secret, servicePB))
+		// TODO: Метод на удаление поста(опцианально)
+		router.With(mwAuth.RequireScope(log, models.ScopeDelete)).
+			Delete("/delete", delete.New(log, cfg.Bucket, blobStore, servicePB))
 
-	// TODO: Метод на вывод определенного поста
-	router.Get("/get_id/id={id}", get_id.New(log, cfg.Secret, cfg.Bucket, awsService, servicePB))
+		// Токенами управляет только сам пользователь, не другой токен
+		router.Group(func(router chi.Router) {
+			router.Use(mwAuth.RequireUser(log))
 
-	// TODO: Метод на удаление поста(опцианально)
-	router.Delete("/delete", delete.New(log, cfg.Secret, cfg.Bucket, awsService, servicePB))
+			router.Get("/tokens", apiTokens.New(log, tokenService))
+
+			router.Post("/tokens", create_token.New(log, tokenService))
+
+			router.Delete("/tokens/{id}", revoke_token.New(log, tokenService))
+		})
+
+		router.Group(func(router chi.Router) {
+			router.Use(mwAuth.RequireAdmin(log))
+
+			router.Post("/admin/revocations", revoke.New(log, verifier, revocations))
+
+			// Счётчики и memstats не для посторонних
+			router.Handle("/debug/vars", expvar.Handler())
+		})
+	})
 
 	//router.Post("/", post.New(log, storage))
 	//router.Post("/", post.New(log))
@@ -128,18 +357,34 @@ func main() {
 	<-done
 	log.Info("stopping server")
 
-	// TODO: move timeout to config
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
 	defer cancel()
 
 	if err := srv.Shutdown(ctx); err != nil {
 		log.Error("failed to stop server", sl.Err(err))
-
-		return
+	} else {
+		log.Info("server stopped")
 	}
 
-	// TODO: close storage
-	log.Info("server stopped")
+	stopReaper()
+	<-reaperDone
+
+	stopRevocations()
+	<-revocationsDone
+
+	// Сначала останавливаем relay, чтобы он не писал в закрывающийся producer
+	stopRelay()
+	<-relayDone
+
+	// Срок остановки сервера мог уйти целиком, поэтому на досылку сообщений отводим свой
+	closeCtx, cancelClose := context.WithTimeout(context.Background(), cfg.KafkaCloseTimeout)
+	defer cancelClose()
+
+	if err := kafkaProd.Close(closeCtx); err != nil {
+		log.Error("failed to flush kafka producer", sl.Err(err))
+	} else {
+		log.Info("kafka producer closed")
+	}
 }
 func setupLogger(env string) *slog.Logger {
 	var log *slog.Logger
@@ -165,3 +410,38 @@ func setupLogger(env string) *slog.Logger {
 
 	return log
 }
+
+func mustBlobStore(log *slog.Logger, cfg config.BlobStore) service.BlobStore {
+	blobStore, err := setupBlobStore(log, cfg)
+	if err != nil {
+		log.Error("failed to init blob store", sl.Err(err))
+		os.Exit(1)
+	}
+	return blobStore
+}
+
+func mustKeyring(log *slog.Logger, cfg config.Encryption) *envelope.Keyring {
+	keyring, err := envelope.NewKeyring(cfg.ActiveKey, cfg.MasterKeys)
+	if err != nil {
+		log.Error("failed to init keyring", sl.Err(err))
+		os.Exit(1)
+	}
+	return keyring
+}
+
+func setupBlobStore(log *slog.Logger, cfg config.BlobStore) (service.BlobStore, error) {
+	switch cfg.Driver {
+	case config.BlobDriverS3:
+		return aws.New(log, aws.Config{
+			Endpoint:        cfg.S3.Endpoint,
+			Region:          cfg.S3.Region,
+			UsePathStyle:    cfg.S3.UsePathStyle,
+			AccessKeyID:     cfg.S3.AccessKeyID,
+			SecretAccessKey: cfg.S3.SecretAccessKey,
+		})
+	case config.BlobDriverLocal:
+		return localfs.New(log, cfg.Local.Root)
+	default:
+		return nil, fmt.Errorf("unknown blob store driver %q", cfg.Driver)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/maestro-milagro/Post_Service_PB/internal/storage/postgres/migrator"
+	"log/slog"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+var errUsage = errors.New("usage: migrate up | down [steps] | status")
+
+// runMigrate implements the "migrate" subcommand.
+func runMigrate(ctx context.Context, log *slog.Logger, m *migrator.Migrator, args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := m.Up(ctx)
+		if err != nil {
+			return err
+		}
+		log.Info("migrations applied", slog.Int("count", applied))
+
+		return nil
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return errUsage
+			}
+			steps = n
+		}
+
+		reverted, err := m.Down(ctx, steps)
+		if err != nil {
+			return err
+		}
+		log.Info("migrations reverted", slog.Int("count", reverted))
+
+		return nil
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED AT")
+		for _, st := range statuses {
+			appliedAt := "pending"
+			if st.AppliedAt != nil {
+				appliedAt = st.AppliedAt.Format(time.RFC3339)
+			}
+			name := st.Name
+			if st.Missing {
+				name = "(missing file)"
+			}
+			fmt.Fprintf(tw, "%d\t%s\t%s\n", st.Version, name, appliedAt)
+		}
+		return tw.Flush()
+	default:
+		return errUsage
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"github.com/maestro-milagro/Post_Service_PB/internal/service/envelope"
+	"github.com/maestro-milagro/Post_Service_PB/internal/service/rotation"
+	"log/slog"
+)
+
+// runRotateKeys implements the "rotate-keys" subcommand, run after a new active master key is configured.
+func runRotateKeys(ctx context.Context, log *slog.Logger, store rotation.Store, keys *envelope.Keyring, args []string) error {
+	fs := flag.NewFlagSet("rotate-keys", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "only report which posts would be rewrapped")
+	batchSize := fs.Int("batch-size", 100, "posts fetched per query")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	stats, err := rotation.NewKeyRotator(log, store, keys, *batchSize, *dryRun).Run(ctx)
+
+	log.Info("key rotation finished",
+		slog.Bool("dry_run", *dryRun),
+		slog.String("active_key", keys.Active()),
+		slog.Int("rewrapped", stats.Rewrapped),
+		slog.Int("skipped", stats.Skipped),
+	)
+
+	return err
+}
@@ -10,16 +10,128 @@ import (
 type Config struct {
 	Env                  string `yaml:"env" env-default:"local"`
 	Secret               string `yaml:"secret"`
+	JWT                  JWT    `yaml:"jwt"`
 	Bucket               string `yaml:"bucket"`
+	SlugLength           int    `yaml:"slug_length" env-default:"10"`
 	KafkaBootstrapServer string `yaml:"kafka_bootstrap_server" env-default:"localhost:9095"`
-	DB                   `yaml:"db"`
-	HTTPServer           `yaml:"http_server"`
+	KafkaConsumerGroup   string `yaml:"kafka_consumer_group" env-default:"post-service-feed"`
+	// KafkaCloseTimeout bounds flushing the producer on shutdown, after the server and workers have stopped
+	KafkaCloseTimeout time.Duration `yaml:"kafka_close_timeout" env-default:"5s"`
+	BlobStore         BlobStore     `yaml:"blob_store"`
+	Encryption        Encryption    `yaml:"encryption"`
+	Diff              Diff          `yaml:"diff"`
+	Highlight         Highlight     `yaml:"highlight"`
+	Outbox            Outbox        `yaml:"outbox"`
+	Reaper            Reaper        `yaml:"reaper"`
+	Revocation        Revocation    `yaml:"revocation"`
+	PostPassword      PostPassword  `yaml:"post_password"`
+	DB                `yaml:"db"`
+	HTTPServer        `yaml:"http_server"`
+}
+
+const (
+	BlobDriverS3    = "s3"
+	BlobDriverLocal = "local"
+)
+
+// JWT configures token verification. Secret above enables HS256 tokens, JWKSFile
+// the RS256/ES256/EdDSA ones; Issuer and Audience are checked when set.
+type JWT struct {
+	JWKSFile string        `yaml:"jwks_file" env:"JWT_JWKS_FILE"`
+	Issuer   string        `yaml:"issuer" env:"JWT_ISSUER"`
+	Audience string        `yaml:"audience" env:"JWT_AUDIENCE"`
+	Leeway   time.Duration `yaml:"leeway" env-default:"30s"`
+}
+
+type BlobStore struct {
+	Driver string         `yaml:"driver" env:"BLOB_STORE_DRIVER" env-default:"s3"`
+	S3     S3BlobStore    `yaml:"s3"`
+	Local  LocalBlobStore `yaml:"local"`
+}
+
+type S3BlobStore struct {
+	Endpoint        string `yaml:"endpoint" env-default:"https://storage.yandexcloud.net"`
+	Region          string `yaml:"region" env-default:"ru-central1"`
+	UsePathStyle    bool   `yaml:"use_path_style"`
+	AccessKeyID     string `yaml:"access_key_id" env:"AWS_ACCESS_KEY_ID"`
+	SecretAccessKey string `yaml:"secret_access_key" env:"AWS_SECRET_ACCESS_KEY"`
+}
+
+type LocalBlobStore struct {
+	Root string `yaml:"root" env-default:"./data/blobs"`
+}
+
+// Encryption configures server-side envelope encryption of post bodies.
+// MasterKeys maps key ids to base64-encoded 32-byte keys; retired keys stay
+// listed until rotate-keys has rewrapped everything under ActiveKey.
+// An empty ActiveKey stores new posts in plaintext.
+type Encryption struct {
+	ActiveKey  string            `yaml:"active_key" env:"ENCRYPTION_ACTIVE_KEY"`
+	MasterKeys map[string]string `yaml:"master_keys" env:"ENCRYPTION_MASTER_KEYS"`
+}
+
+// Diff bounds the cost of a diff. MaxLines caps the lines of each side; past
+// MaxEdits differing lines a changed region is shown as replaced whole.
+type Diff struct {
+	// MaxSize caps each side of a diff, the service refuses larger posts
+	MaxSize  int64 `yaml:"max_size" env-default:"1048576"`
+	MaxLines int   `yaml:"max_lines" env-default:"50000"`
+	MaxEdits int   `yaml:"max_edits" env-default:"1000"`
+}
+
+// Highlight configures the HTML view of text posts. CacheSize bounds the
+// rendered pages kept in memory, in bytes.
+type Highlight struct {
+	Style     string `yaml:"style" env-default:"github"`
+	MaxSize   int64  `yaml:"max_size" env-default:"1048576"`
+	CacheSize int64  `yaml:"cache_size" env-default:"67108864"`
+}
+
+type Outbox struct {
+	PollInterval time.Duration `yaml:"poll_interval" env-default:"1s"`
+	BatchSize    int           `yaml:"batch_size" env-default:"100"`
+	// Lease is how long a claimed event stays hidden from other relays
+	Lease      time.Duration `yaml:"lease" env-default:"30s"`
+	MinBackoff time.Duration `yaml:"min_backoff" env-default:"1s"`
+	MaxBackoff time.Duration `yaml:"max_backoff" env-default:"5m"`
+	// Retention is how long delivered events are kept before they are purged
+	Retention time.Duration `yaml:"retention" env-default:"168h"`
+}
+
+type Reaper struct {
+	Interval  time.Duration `yaml:"interval" env-default:"1m"`
+	BatchSize int           `yaml:"batch_size" env-default:"100"`
+}
+
+// Revocation configures the revoked JWT list. Tokens revoked on another
+// instance are rejected here within RefreshInterval. MaxTokenTTL must be at
+// least the lifetime of the tokens the auth service issues: a jti revoked
+// without its token is remembered that long.
+type Revocation struct {
+	RefreshInterval time.Duration `yaml:"refresh_interval" env-default:"30s"`
+	MaxTokenTTL     time.Duration `yaml:"max_token_ttl" env-default:"720h"`
+}
+
+// PostPassword limits wrong download passwords per post: after MaxAttempts
+// failures the post is locked until Window has passed since the first one.
+// The limit applies to each instance of the service separately.
+type PostPassword struct {
+	MaxAttempts int           `yaml:"max_attempts" env-default:"5"`
+	Window      time.Duration `yaml:"window" env-default:"15m"`
 }
 
 type HTTPServer struct {
 	Address     string        `yaml:"address" env-default:"localhost:8080"`
 	Timeout     time.Duration `yaml:"timeout" env-default:"4s"`
 	IdleTimeout time.Duration `yaml:"idle_timeout" env-default:"60s"`
+	// TransferTimeout replaces Timeout for uploads and raw downloads, which stream large bodies
+	TransferTimeout time.Duration `yaml:"transfer_timeout" env-default:"5m"`
+	MaxUploadSize   int64         `yaml:"max_upload_size" env-default:"10485760"`
+	// MaxInlineSize caps the posts returned inside JSON, larger ones are read from /raw
+	MaxInlineSize int64 `yaml:"max_inline_size" env-default:"1048576"`
+	// MaxPostTTL caps how far ahead a post may expire, zero for no cap
+	MaxPostTTL      time.Duration `yaml:"max_post_ttl" env-default:"8760h"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env-default:"10s"`
 	// User        string        `yaml:"user" env-required:"true"`
 	// Password    string        `yaml:"password" env-required:"true" env:"HTTP_SERVER_PASSWORD"`
 }
@@ -32,13 +144,14 @@ type DB struct {
 	Port     string `yaml:"port"`
 	DBname   string `yaml:"dbname"`
 	SSLmode  string `yaml:"sslmode"`
+	// MigrateOnStart applies pending migrations before the server starts serving
+	MigrateOnStart bool `yaml:"migrate_on_start" env:"DB_MIGRATE_ON_START"`
 }
 
 func MustLoad() *Config {
-	//	configPath := os.Getenv("CONFIG_PATH")
-	configPath := "config/prod.yaml"
+	configPath := os.Getenv("CONFIG_PATH")
 	if configPath == "" {
-		log.Fatal("CONFIG_PATH is not set")
+		configPath = "config/prod.yaml"
 	}
 
 	// check if file exists
@@ -0,0 +1,55 @@
+// Package access turns requests for a post into service.PostRef and the errors
+// of service.Resolve and service.Open into responses.
+package access
+
+import (
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/maestro-milagro/Post_Service_PB/internal/service"
+	"github.com/maestro-milagro/Post_Service_PB/internal/storage"
+	"net/http"
+	"strconv"
+)
+
+// Ref names the post of the {slug} route parameter, or of {id} on the owner-only routes.
+func Ref(r *http.Request) service.PostRef {
+	return service.PostRef{
+		Slug: chi.URLParam(r, "slug"),
+		ID:   chi.URLParam(r, "id"),
+	}
+}
+
+// Password returns the download password sent with the request.
+func Password(r *http.Request) string {
+	return r.Header.Get(service.PasswordHeader)
+}
+
+// SetRetryAfter tells the client when a post locked after wrong passwords opens again.
+func SetRetryAfter(w http.ResponseWriter, err error) {
+	var locked *service.LockedError
+	if errors.As(err, &locked) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(locked.RetryAfter.Seconds())+1))
+	}
+}
+
+// Status maps err to the response status and message. Errors other than those of
+// the access checks are reported as internal with the fallback message.
+func Status(err error, fallback string) (int, string) {
+	switch {
+	case errors.Is(err, service.ErrInvalidID):
+		return http.StatusBadRequest, "invalid request"
+	case errors.Is(err, storage.ErrPostNotFound):
+		return http.StatusNotFound, "post not found"
+	case errors.Is(err, service.ErrPostExpired):
+		return http.StatusGone, "post expired"
+	case errors.Is(err, service.ErrPostConsumed):
+		return http.StatusGone, "post already read"
+	case errors.Is(err, service.ErrPasswordRequired):
+		return http.StatusUnauthorized, "password required"
+	case errors.Is(err, service.ErrWrongPassword):
+		return http.StatusForbidden, "wrong password"
+	case errors.Is(err, service.ErrTooManyAttempts):
+		return http.StatusTooManyRequests, "too many password attempts"
+	}
+	return http.StatusInternalServerError, fallback
+}
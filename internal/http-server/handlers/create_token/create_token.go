@@ -0,0 +1,124 @@
+package create_token
+
+import (
+	"context"
+	"errors"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/maestro-milagro/Post_Service_PB/internal/lib/auth"
+	"github.com/maestro-milagro/Post_Service_PB/internal/lib/sl"
+	"github.com/maestro-milagro/Post_Service_PB/internal/models"
+	"github.com/maestro-milagro/Post_Service_PB/internal/service/tokens"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+type Request struct {
+	Name string `json:"name"`
+	// Scopes is a subset of read, write and delete.
+	Scopes []string `json:"scopes"`
+	// ExpiresIn is a duration such as "720h"; tokens without it never expire.
+	ExpiresIn string `json:"expires_in"`
+}
+
+type Response struct {
+	// Token is shown only once, the service keeps nothing but its hash.
+	Token    string          `json:"token"`
+	APIToken models.APIToken `json:"api_token"`
+	models.Response
+}
+
+type Creator interface {
+	Create(ctx context.Context, email string, name string, scopes []string, expiresAt *time.Time) (models.APIToken, string, error)
+}
+
+// New issues a personal API token to the signed-in user.
+func New(log *slog.Logger, creator Creator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.create_token.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		principal, ok := auth.FromContext(r.Context())
+		if !ok {
+			log.Error("request is not authenticated")
+
+			render.Status(r, http.StatusUnauthorized)
+
+			render.JSON(w, r, models.Error("unauthorized"))
+
+			return
+		}
+
+		var req Request
+
+		err := render.DecodeJSON(r.Body, &req)
+		if errors.Is(err, io.EOF) {
+			log.Error("request body is empty")
+
+			render.Status(r, http.StatusBadRequest)
+
+			render.JSON(w, r, models.Error("empty request"))
+
+			return
+		}
+		if err != nil {
+			log.Error("failed to decode request body", sl.Err(err))
+
+			render.Status(r, http.StatusBadRequest)
+
+			render.JSON(w, r, models.Error("failed to decode request"))
+
+			return
+		}
+
+		var expiresAt *time.Time
+		if req.ExpiresIn != "" {
+			ttl, err := time.ParseDuration(req.ExpiresIn)
+			if err != nil || ttl <= 0 {
+				log.Info("invalid expires_in", slog.String("expires_in", req.ExpiresIn))
+
+				render.Status(r, http.StatusBadRequest)
+
+				render.JSON(w, r, models.Error("invalid expires_in"))
+
+				return
+			}
+			t := time.Now().Add(ttl)
+			expiresAt = &t
+		}
+
+		token, secret, err := creator.Create(r.Context(), principal.Email, req.Name, req.Scopes, expiresAt)
+		if err != nil {
+			if errors.Is(err, tokens.ErrInvalidName) || errors.Is(err, tokens.ErrInvalidScope) {
+				log.Info("invalid token request", sl.Err(err))
+
+				render.Status(r, http.StatusBadRequest)
+
+				render.JSON(w, r, models.Error(err.Error()))
+
+				return
+			}
+			log.Error("failed to create token", sl.Err(err))
+
+			render.Status(r, http.StatusInternalServerError)
+
+			render.JSON(w, r, models.Error("failed to create token"))
+
+			return
+		}
+
+		render.Status(r, http.StatusCreated)
+
+		render.JSON(w, r, Response{
+			Token:    secret,
+			APIToken: token,
+			Response: models.OK(),
+		})
+	}
+}
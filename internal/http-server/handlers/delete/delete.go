@@ -5,46 +5,52 @@ import (
 	"errors"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/render"
-	"github.com/maestro-milagro/Post_Service_PB/internal/lib/jwt"
+	"github.com/maestro-milagro/Post_Service_PB/internal/lib/auth"
 	"github.com/maestro-milagro/Post_Service_PB/internal/lib/sl"
 	"github.com/maestro-milagro/Post_Service_PB/internal/models"
-	"github.com/maestro-milagro/Post_Service_PB/internal/storage"
+	"github.com/maestro-milagro/Post_Service_PB/internal/service"
 	"io"
 	"log/slog"
 	"net/http"
 )
 
 type Request struct {
-	IDs   []int  `json:"ids"`
-	Token string `json:"token"`
+	IDs []int `json:"ids"`
 }
 
 type Response struct {
+	Results []models.DeleteResult `json:"results"`
 	models.Response
 }
 
 type Deleter interface {
-	Delete(ctx context.Context, ids []int) ([]string, error)
-}
-
-type CloudDeleter interface {
-	DeleteObjects(bucketName string, objectKeys []string) error
+	Delete(ctx context.Context, email string, isAdmin bool, ids []int) ([]models.DeleteResult, error)
 }
 
 func New(log *slog.Logger,
-	secret string,
 	bucketName string,
-	cloud CloudDeleter,
+	cloud service.BlobStore,
 	deleter Deleter,
 ) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		const op = "handlers.get_id.New"
+		const op = "handlers.delete.New"
 
 		log := log.With(
 			slog.String("op", op),
 			slog.String("request_id", middleware.GetReqID(r.Context())),
 		)
 
+		principal, ok := auth.FromContext(r.Context())
+		if !ok {
+			log.Error("request is not authenticated")
+
+			render.Status(r, http.StatusUnauthorized)
+
+			render.JSON(w, r, models.Error("unauthorized"))
+
+			return
+		}
+
 		var req Request
 
 		err := render.DecodeJSON(r.Body, &req)
@@ -57,63 +63,54 @@ func New(log *slog.Logger,
 
 			return
 		}
-		_, err = jwt.VerifyToken(log, secret, req.Token)
 		if err != nil {
-			log.Error("failed to verify token", sl.Err(err))
+			log.Error("failed to decode request body", sl.Err(err))
 
-			render.Status(r, http.StatusUnauthorized)
+			render.Status(r, http.StatusBadRequest)
 
-			render.JSON(w, r, models.Error("invalid token"))
+			render.JSON(w, r, models.Error("failed to decode request"))
 
 			return
 		}
 
-		//id := chi.URLParam(r, "id")
-		//if id == "" {
-		//	log.Info("id is empty")
-		//
-		//	render.Status(r, http.StatusBadRequest)
-		//
-		//	render.JSON(w, r, models.Error("invalid request"))
-		//
-		//	return
-		//}
-		if err != nil {
-			log.Error("failed to parse id", sl.Err(err))
-		}
-		delObjects, err := deleter.Delete(r.Context(), req.IDs)
-		if err != nil {
-			if errors.Is(err, storage.ErrUserNotFound) {
-				log.Warn("users not found", sl.Err(err))
-
-				render.Status(r, http.StatusNotFound)
+		if len(req.IDs) == 0 {
+			log.Info("ids are empty")
 
-				render.JSON(w, r, models.Error("invalid credentials"))
-
-				return
-			}
-			log.Error("failed to delete users", sl.Err(err))
-
-			render.Status(r, http.StatusInternalServerError)
+			render.Status(r, http.StatusBadRequest)
 
-			render.JSON(w, r, models.Error("failed to delete user"))
+			render.JSON(w, r, models.Error("invalid request"))
 
 			return
 		}
 
-		// TODO: aws download
-		err = cloud.DeleteObjects(bucketName, delObjects)
+		results, err := deleter.Delete(r.Context(), principal.Email, principal.IsAdmin(), req.IDs)
 		if err != nil {
-			log.Error("failed to download file", sl.Err(err))
+			log.Error("failed to delete posts", sl.Err(err))
 
 			render.Status(r, http.StatusInternalServerError)
 
-			render.JSON(w, r, models.Error("failed to download file"))
+			render.JSON(w, r, models.Error("failed to delete posts"))
 
 			return
 		}
 
+		delObjects := make([]string, 0, len(results))
+		for _, res := range results {
+			if res.Status == models.DeleteStatusDeleted {
+				delObjects = append(delObjects, res.Keys...)
+			}
+		}
+
+		if len(delObjects) > 0 {
+			// Строки уже удалены, поэтому ошибка здесь оставляет только осиротевшие объекты
+			err = cloud.DeleteObjects(r.Context(), bucketName, delObjects)
+			if err != nil {
+				log.Error("failed to delete objects", sl.Err(err), slog.Any("keys", delObjects))
+			}
+		}
+
 		render.JSON(w, r, Response{
+			Results:  results,
 			Response: models.OK(),
 		})
 	}
@@ -0,0 +1,275 @@
+package diff
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/maestro-milagro/Post_Service_PB/internal/http-server/access"
+	"github.com/maestro-milagro/Post_Service_PB/internal/lib/auth"
+	"github.com/maestro-milagro/Post_Service_PB/internal/lib/diff"
+	"github.com/maestro-milagro/Post_Service_PB/internal/lib/sl"
+	"github.com/maestro-milagro/Post_Service_PB/internal/models"
+	"github.com/maestro-milagro/Post_Service_PB/internal/service"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// contextLines is the number of unchanged lines shown around each change.
+const contextLines = 3
+
+var (
+	errBinary   = errors.New("binary content cannot be diffed")
+	errTooLarge = errors.New("content too large to diff")
+	errNoPrev   = errors.New("post has a single revision")
+	errBurn     = errors.New("burn-after-read posts cannot be diffed")
+)
+
+// Side names one of the compared versions.
+type Side struct {
+	PostID   int64  `json:"post_id"`
+	Slug     string `json:"slug"`
+	Revision int    `json:"revision"`
+	FileName string `json:"file_name"`
+}
+
+type Response struct {
+	From    Side        `json:"from"`
+	To      Side        `json:"to"`
+	Unified string      `json:"unified"`
+	Hunks   []diff.Hunk `json:"hunks"`
+	models.Response
+}
+
+type PostGetter interface {
+	GetById(ctx context.Context, viewer string, id int) (models.PostUser, error)
+	Resolve(ctx context.Context, viewer string, ref service.PostRef, password string) (models.PostUser, error)
+	Revision(ctx context.Context, email string, id int, n int) (models.PostUser, models.PostRevision, error)
+}
+
+// New diffs two revisions of the caller's post, ?from=..&to=.. defaulting to the
+// previous and the current one, or a revision of it against another post, ?post=<slug>.
+func New(log *slog.Logger,
+	bucketName string,
+	maxSize int64,
+	limits diff.Limits,
+	cloud *service.Content,
+	getter PostGetter,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.diff.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		principal, ok := auth.FromContext(r.Context())
+		if !ok {
+			log.Error("request is not authenticated")
+
+			render.Status(r, http.StatusUnauthorized)
+
+			render.JSON(w, r, models.Error("unauthorized"))
+
+			return
+		}
+		email := principal.Email
+
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			log.Info("invalid id", sl.Err(err))
+
+			render.Status(r, http.StatusBadRequest)
+
+			render.JSON(w, r, models.Error("invalid request"))
+
+			return
+		}
+
+		query := r.URL.Query()
+		from, errFrom := optionalInt(query.Get("from"))
+		to, errTo := optionalInt(query.Get("to"))
+		other := query.Get("post")
+		if errFrom != nil || errTo != nil || (other != "" && to != 0) {
+			log.Info("invalid query", slog.String("query", r.URL.RawQuery))
+
+			render.Status(r, http.StatusBadRequest)
+
+			render.JSON(w, r, models.Error("invalid request"))
+
+			return
+		}
+
+		left, right, err := versions(r, getter, email, id, from, to, other)
+		if err != nil {
+			log.Info("failed to resolve versions", sl.Err(err))
+
+			access.SetRetryAfter(w, err)
+
+			status, msg := errorStatus(err)
+
+			render.Status(r, status)
+
+			render.JSON(w, r, models.Error(msg))
+
+			return
+		}
+
+		var a, b string
+		a, err = text(r.Context(), cloud, bucketName, left, maxSize)
+		if err == nil {
+			b, err = text(r.Context(), cloud, bucketName, right, maxSize)
+		}
+		if err != nil {
+			log.Info("failed to read content", sl.Err(err))
+
+			status, msg := errorStatus(err)
+
+			render.Status(r, status)
+
+			render.JSON(w, r, models.Error(msg))
+
+			return
+		}
+
+		hunks, err := diff.Hunks(r.Context(), a, b, contextLines, limits)
+		if err != nil {
+			log.Info("failed to diff content", sl.Err(err))
+
+			status, msg := errorStatus(err)
+
+			render.Status(r, status)
+
+			render.JSON(w, r, models.Error(msg))
+
+			return
+		}
+
+		render.JSON(w, r, Response{
+			From:     side(left),
+			To:       side(right),
+			Unified:  diff.Unified("a/"+left.FileName, "b/"+right.FileName, hunks),
+			Hunks:    hunks,
+			Response: models.OK(),
+		})
+	}
+}
+
+// versions resolves the two posts to compare, both as of the requested revision.
+func versions(r *http.Request, getter PostGetter,
+	email string, id, from, to int, other string) (models.PostUser, models.PostUser, error) {
+	ctx := r.Context()
+
+	current, err := getter.GetById(ctx, email, id)
+	if err != nil {
+		return models.PostUser{}, models.PostUser{}, err
+	}
+
+	revision := func(n int) (models.PostUser, error) {
+		if n == current.Revision {
+			return current, nil
+		}
+		post, _, err := getter.Revision(ctx, email, id, n)
+		return post, err
+	}
+
+	if other == "" {
+		if to == 0 {
+			to = current.Revision
+		}
+		if from == 0 {
+			from = to - 1
+		}
+		if from < 1 {
+			return models.PostUser{}, models.PostUser{}, errNoPrev
+		}
+
+		left, err := revision(from)
+		if err != nil {
+			return models.PostUser{}, models.PostUser{}, err
+		}
+		right, err := revision(to)
+		return left, right, err
+	}
+
+	left := current
+	if from != 0 {
+		if left, err = revision(from); err != nil {
+			return models.PostUser{}, models.PostUser{}, err
+		}
+	}
+
+	right, err := getter.Resolve(ctx, email, service.PostRef{Slug: other}, access.Password(r))
+	if err != nil {
+		return models.PostUser{}, models.PostUser{}, err
+	}
+
+	return left, right, nil
+}
+
+func text(ctx context.Context, cloud *service.Content, bucketName string, post models.PostUser, maxSize int64) (string, error) {
+	// Содержимое, зашифрованное клиентом, сервис не читает
+	if post.ClientEncrypted {
+		return "", errBinary
+	}
+	// Чтение ради диффа не должно ни «сжигать» пост, ни обходить это
+	if post.BurnAfterRead {
+		return "", errBurn
+	}
+	if post.Size > maxSize {
+		return "", errTooLarge
+	}
+
+	data, err := cloud.ReadPost(ctx, bucketName, post, maxSize)
+	if errors.Is(err, service.ErrPostTooLarge) {
+		return "", errTooLarge
+	}
+	if err != nil {
+		return "", err
+	}
+	if !diff.IsText(data) {
+		return "", errBinary
+	}
+
+	return string(data), nil
+}
+
+func side(post models.PostUser) Side {
+	return Side{
+		PostID:   post.ID,
+		Slug:     post.Slug,
+		Revision: post.Revision,
+		FileName: post.FileName,
+	}
+}
+
+func optionalInt(s string) (int, error) {
+	if s == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err == nil && n < 1 {
+		err = fmt.Errorf("must be positive: %d", n)
+	}
+	return n, err
+}
+
+func errorStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, service.ErrNoRevision):
+		return http.StatusNotFound, "revision not found"
+	case errors.Is(err, errBurn):
+		return http.StatusConflict, err.Error()
+	case errors.Is(err, errNoPrev):
+		return http.StatusBadRequest, err.Error()
+	case errors.Is(err, errBinary):
+		return http.StatusUnsupportedMediaType, err.Error()
+	case errors.Is(err, errTooLarge), errors.Is(err, diff.ErrTooManyLines):
+		return http.StatusRequestEntityTooLarge, err.Error()
+	}
+	return access.Status(err, "failed to diff posts")
+}
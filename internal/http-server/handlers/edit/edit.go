@@ -0,0 +1,207 @@
+package edit
+
+import (
+	"context"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/maestro-milagro/Post_Service_PB/internal/lib/auth"
+	"github.com/maestro-milagro/Post_Service_PB/internal/lib/objkey"
+	"github.com/maestro-milagro/Post_Service_PB/internal/lib/sl"
+	"github.com/maestro-milagro/Post_Service_PB/internal/lib/upload"
+	"github.com/maestro-milagro/Post_Service_PB/internal/models"
+	"github.com/maestro-milagro/Post_Service_PB/internal/service"
+	"github.com/maestro-milagro/Post_Service_PB/internal/storage"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type Response struct {
+	Revision models.PostRevision `json:"revision"`
+	models.Response
+}
+
+type Editor interface {
+	Editable(ctx context.Context, email string, id int) (models.PostUser, error)
+	Edit(ctx context.Context, email string, rev models.PostRevision) (models.PostRevision, error)
+}
+
+// New uploads a new revision of the post from a multipart form; without an Authorization
+// header the auth middleware reads the token from a "token" field preceding the file part. Earlier revisions and their objects are kept.
+func New(log *slog.Logger,
+	bucket string,
+	maxUploadSize int64,
+	transferTimeout time.Duration,
+	editor Editor,
+	uploader *service.Content,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.edit.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			log.Info("invalid id", sl.Err(err))
+
+			render.Status(r, http.StatusBadRequest)
+
+			render.JSON(w, r, models.Error("invalid request"))
+
+			return
+		}
+
+		principal, ok := auth.FromContext(r.Context())
+		if !ok {
+			log.Error("request is not authenticated")
+
+			render.Status(r, http.StatusUnauthorized)
+
+			render.JSON(w, r, models.Error("unauthorized"))
+
+			return
+		}
+		email := principal.Email
+
+		// Таймаут сервера рассчитан на короткие запросы, большие файлы загружаются дольше
+		if err := http.NewResponseController(w).SetReadDeadline(time.Now().Add(transferTimeout)); err != nil {
+			log.Warn("failed to extend read deadline", sl.Err(err))
+		}
+
+		form := upload.NewForm(w, r, maxUploadSize)
+
+		part, err := form.NextFile()
+		if err != nil {
+			log.Error("failed to read form", sl.Err(err))
+
+			status, msg := upload.Status(err, "failed to upload file")
+
+			render.Status(r, status)
+
+			render.JSON(w, r, models.Error(msg))
+
+			return
+		}
+
+		// Права проверяем до загрузки, чтобы не принимать файл впустую
+		post, err := editor.Editable(r.Context(), email, id)
+		if err != nil {
+			log.Info("post is not editable", sl.Err(err))
+
+			status, msg := errorStatus(err)
+
+			render.Status(r, status)
+
+			render.JSON(w, r, models.Error(msg))
+
+			return
+		}
+
+		key, err := objkey.New(email)
+		if err != nil {
+			log.Error("failed to generate object key", sl.Err(err))
+
+			render.Status(r, http.StatusInternalServerError)
+
+			render.JSON(w, r, models.Error("failed to upload file"))
+
+			return
+		}
+
+		body, err := form.Open(part, post.ClientEncrypted)
+		if err != nil {
+			log.Error("failed to read file", sl.Err(err), slog.Int64("limit", maxUploadSize))
+
+			status, msg := upload.Status(err, "failed to upload file")
+
+			render.Status(r, status)
+
+			render.JSON(w, r, models.Error(msg))
+
+			return
+		}
+
+		sealing, err := uploader.UploadPost(r.Context(), bucket, key, body, body.ContentType())
+		if err != nil {
+			log.Error("failed to upload file", sl.Err(err), slog.Int64("limit", maxUploadSize))
+
+			status, msg := upload.Status(body.Cause(err), "failed to upload file")
+
+			render.Status(r, status)
+
+			render.JSON(w, r, models.Error(msg))
+
+			return
+		}
+
+		// Ревизия не будет сохранена, поэтому загруженный объект никому не принадлежит
+		discard := func() {
+			if err := uploader.DeleteObjects(context.WithoutCancel(r.Context()), bucket, []string{key}); err != nil {
+				log.Error("failed to delete orphaned object", sl.Err(err), slog.String("key", key))
+			}
+		}
+
+		if err := form.Rest(); err != nil {
+			log.Error("failed to read form", sl.Err(err))
+			discard()
+
+			status, msg := upload.Status(err, "failed to save revision")
+
+			render.Status(r, status)
+
+			render.JSON(w, r, models.Error(msg))
+
+			return
+		}
+
+		rev, err := editor.Edit(r.Context(), email, models.PostRevision{
+			PostID:      int64(id),
+			Key:         key,
+			FileName:    part.FileName(),
+			ContentType: body.ContentType(),
+			Size:        body.Size(),
+			Checksum:    body.Checksum(),
+			EncKeyID:    sealing.KeyID,
+			EncDataKey:  sealing.DataKey,
+		})
+		if err != nil {
+			log.Error("failed to save revision", sl.Err(err))
+			discard()
+
+			status, msg := errorStatus(err)
+
+			render.Status(r, status)
+
+			render.JSON(w, r, models.Error(msg))
+
+			return
+		}
+
+		render.JSON(w, r, Response{
+			Revision: rev,
+			Response: models.OK(),
+		})
+	}
+}
+
+func errorStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, storage.ErrPostNotFound):
+		return http.StatusNotFound, "post not found"
+	case errors.Is(err, service.ErrNotOwner):
+		return http.StatusForbidden, "only the author can edit the post"
+	case errors.Is(err, service.ErrPostExpired):
+		return http.StatusGone, "post expired"
+	case errors.Is(err, service.ErrPostConsumed):
+		return http.StatusGone, "post already read"
+	case errors.Is(err, service.ErrNotEditable):
+		return http.StatusConflict, err.Error()
+	}
+	return http.StatusInternalServerError, "failed to save revision"
+}
@@ -0,0 +1,113 @@
+package feed
+
+import (
+	"context"
+	"errors"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/maestro-milagro/Post_Service_PB/internal/lib/auth"
+	"github.com/maestro-milagro/Post_Service_PB/internal/lib/sl"
+	"github.com/maestro-milagro/Post_Service_PB/internal/models"
+	"github.com/maestro-milagro/Post_Service_PB/internal/service"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+const (
+	defaultLimit = 20
+	maxLimit     = 100
+)
+
+type Response struct {
+	Items      []models.FeedItem `json:"items"`
+	NextCursor int64             `json:"next_cursor,omitempty"`
+	models.Response
+}
+
+type FeedGetter interface {
+	Feed(ctx context.Context, email string, cursor int64, limit int) ([]models.FeedItem, int64, error)
+}
+
+// New serves the caller's feed, newest first. Pages are requested with
+// ?limit=N&cursor=C, where C is next_cursor from the previous page.
+func New(log *slog.Logger,
+	feedGetter FeedGetter,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.feed.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		principal, ok := auth.FromContext(r.Context())
+		if !ok {
+			log.Error("request is not authenticated")
+
+			render.Status(r, http.StatusUnauthorized)
+
+			render.JSON(w, r, models.Error("unauthorized"))
+
+			return
+		}
+		email := principal.Email
+
+		var err error
+
+		limit := defaultLimit
+		if v := r.URL.Query().Get("limit"); v != "" {
+			limit, err = strconv.Atoi(v)
+			if err != nil || limit < 1 || limit > maxLimit {
+				log.Info("invalid limit", slog.String("limit", v))
+
+				render.Status(r, http.StatusBadRequest)
+
+				render.JSON(w, r, models.Error("invalid limit"))
+
+				return
+			}
+		}
+
+		var cursor int64
+		if v := r.URL.Query().Get("cursor"); v != "" {
+			cursor, err = strconv.ParseInt(v, 10, 64)
+			if err != nil || cursor < 1 {
+				log.Info("invalid cursor", slog.String("cursor", v))
+
+				render.Status(r, http.StatusBadRequest)
+
+				render.JSON(w, r, models.Error("invalid cursor"))
+
+				return
+			}
+		}
+
+		items, next, err := feedGetter.Feed(r.Context(), email, cursor, limit)
+		if err != nil {
+			if errors.Is(err, service.ErrUserNotFound) {
+				log.Warn("user not found", sl.Err(err))
+
+				render.Status(r, http.StatusNotFound)
+
+				render.JSON(w, r, models.Error("user not found"))
+
+				return
+			}
+			log.Error("failed to get feed", sl.Err(err))
+
+			render.Status(r, http.StatusInternalServerError)
+
+			render.JSON(w, r, models.Error("failed to get feed"))
+
+			return
+		}
+
+		render.JSON(w, r, Response{
+			Items:      items,
+			NextCursor: next,
+			Response:   models.OK(),
+		})
+	}
+}
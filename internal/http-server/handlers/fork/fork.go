@@ -0,0 +1,171 @@
+package fork
+
+import (
+	"context"
+	"errors"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/maestro-milagro/Post_Service_PB/internal/http-server/access"
+	"github.com/maestro-milagro/Post_Service_PB/internal/lib/auth"
+	"github.com/maestro-milagro/Post_Service_PB/internal/lib/objkey"
+	"github.com/maestro-milagro/Post_Service_PB/internal/lib/sl"
+	"github.com/maestro-milagro/Post_Service_PB/internal/models"
+	"github.com/maestro-milagro/Post_Service_PB/internal/service"
+	"io"
+	"log/slog"
+	"net/http"
+)
+
+type Request struct {
+	// Visibility of the fork, no wider than that of the source. When empty the fork
+	// keeps the visibility of the source, or is private if that is not allowed.
+	Visibility string `json:"visibility"`
+}
+
+type Response struct {
+	Id     int            `json:"id"`
+	Slug   string         `json:"slug"`
+	Parent models.PostRef `json:"parent"`
+	models.Response
+}
+
+type Forker interface {
+	Resolve(ctx context.Context, viewer string, ref service.PostRef, password string) (models.PostUser, error)
+	Fork(ctx context.Context, email string, source models.PostUser, key string, visibility string) (int64, string, error)
+}
+
+// New copies the post into the caller's account. The object is copied inside the
+// blob store, and the new post notifies the caller's followers like any other.
+func New(log *slog.Logger,
+	bucketName string,
+	cloud *service.Content,
+	forker Forker,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.fork.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		principal, ok := auth.FromContext(r.Context())
+		if !ok {
+			log.Error("request is not authenticated")
+
+			render.Status(r, http.StatusUnauthorized)
+
+			render.JSON(w, r, models.Error("unauthorized"))
+
+			return
+		}
+		email := principal.Email
+
+		var req Request
+
+		// Тело необязательно: токен может прийти в заголовке, а видимость по умолчанию берётся у исходного поста
+		err := render.DecodeJSON(r.Body, &req)
+		if err != nil && !errors.Is(err, io.EOF) {
+			log.Error("failed to decode request body", sl.Err(err))
+
+			render.Status(r, http.StatusBadRequest)
+
+			render.JSON(w, r, models.Error("failed to decode request"))
+
+			return
+		}
+
+		// Форк не читает пост как читатель, поэтому одноразовый пост не «сжигается», а отклоняется
+		source, err := forker.Resolve(r.Context(), email, access.Ref(r), access.Password(r))
+		if err == nil {
+			err = service.Forkable(source)
+		}
+		if err != nil {
+			log.Info("post cannot be forked", sl.Err(err))
+
+			access.SetRetryAfter(w, err)
+
+			status, msg := errorStatus(err)
+
+			render.Status(r, status)
+
+			render.JSON(w, r, models.Error(msg))
+
+			return
+		}
+
+		if req.Visibility != "" && !models.ValidVisibility(req.Visibility) {
+			log.Info("invalid visibility", slog.String("visibility", req.Visibility))
+
+			render.Status(r, http.StatusBadRequest)
+
+			render.JSON(w, r, models.Error("invalid visibility"))
+
+			return
+		}
+
+		// Проверяем до копирования объекта, чтобы не копировать впустую
+		visibility, err := service.ForkVisibility(source, email, req.Visibility)
+		if err != nil {
+			log.Info("fork visibility is not allowed", slog.String("visibility", req.Visibility))
+
+			render.Status(r, http.StatusForbidden)
+
+			render.JSON(w, r, models.Error(err.Error()))
+
+			return
+		}
+
+		key, err := objkey.New(email)
+		if err != nil {
+			log.Error("failed to generate object key", sl.Err(err))
+
+			render.Status(r, http.StatusInternalServerError)
+
+			render.JSON(w, r, models.Error("failed to fork post"))
+
+			return
+		}
+
+		// Объект копируется внутри хранилища; зашифрованное тело копируется как есть и остаётся под тем же ключом данных
+		if err := cloud.CopyObject(r.Context(), bucketName, source.Key, key); err != nil {
+			log.Error("failed to copy object", sl.Err(err))
+
+			render.Status(r, http.StatusInternalServerError)
+
+			render.JSON(w, r, models.Error("failed to fork post"))
+
+			return
+		}
+
+		id, postSlug, err := forker.Fork(r.Context(), email, source, key, visibility)
+		if err != nil {
+			log.Error("failed to save fork", sl.Err(err))
+
+			// Форк не сохранён, поэтому скопированный объект никому не принадлежит
+			if err := cloud.DeleteObjects(context.WithoutCancel(r.Context()), bucketName, []string{key}); err != nil {
+				log.Error("failed to delete orphaned object", sl.Err(err), slog.String("key", key))
+			}
+
+			render.Status(r, http.StatusInternalServerError)
+
+			render.JSON(w, r, models.Error("failed to fork post"))
+
+			return
+		}
+
+		render.JSON(w, r, Response{
+			Id:       int(id),
+			Slug:     postSlug,
+			Parent:   source.Ref(),
+			Response: models.OK(),
+		})
+	}
+}
+
+func errorStatus(err error) (int, string) {
+	if errors.Is(err, service.ErrNotForkable) {
+		return http.StatusConflict, err.Error()
+	}
+	return access.Status(err, "failed to fork post")
+}
@@ -3,37 +3,36 @@ package get_all
 import (
 	"context"
 	"errors"
-	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/render"
-	"github.com/maestro-milagro/Post_Service_PB/internal/lib/jwt"
+	"github.com/maestro-milagro/Post_Service_PB/internal/lib/auth"
 	"github.com/maestro-milagro/Post_Service_PB/internal/lib/sl"
 	"github.com/maestro-milagro/Post_Service_PB/internal/models"
-	"github.com/maestro-milagro/Post_Service_PB/internal/service"
-	"io"
 	"log/slog"
 	"net/http"
+	"net/url"
+	"strconv"
+	"time"
 )
 
-type Request struct {
-	Token string `json:"token"`
-}
+const (
+	defaultLimit = 20
+	maxLimit     = 100
+)
 
 type Response struct {
-	UsersInfo []models.PostUser `json:"users_info"`
+	UsersInfo   []models.PostView `json:"users_info"`
+	NextAfterID int64             `json:"next_after_id,omitempty"`
 	models.Response
 }
 
 type AllGetter interface {
-	GetAll(ctx context.Context) ([]models.PostUser, error)
-}
-
-type CloudListDownloader interface {
-	DownloadList(bucketName string) ([]types.Object, error)
+	GetAll(ctx context.Context, viewer string, filter models.PostFilter) ([]models.PostUser, int64, error)
 }
 
+// New lists posts in id order. Query parameters: limit, after_id (next_after_id
+// of the previous page), email, created_from and created_to (RFC 3339).
 func New(log *slog.Logger,
-	secret string,
 	byIDGetter AllGetter,
 ) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -44,51 +43,89 @@ func New(log *slog.Logger,
 			slog.String("request_id", middleware.GetReqID(r.Context())),
 		)
 
-		var req Request
+		principal, ok := auth.FromContext(r.Context())
+		if !ok {
+			log.Error("request is not authenticated")
 
-		err := render.DecodeJSON(r.Body, &req)
-		if errors.Is(err, io.EOF) {
-			log.Error("request body is empty")
-
-			render.Status(r, http.StatusBadRequest)
+			render.Status(r, http.StatusUnauthorized)
 
-			render.JSON(w, r, models.Error("empty request"))
+			render.JSON(w, r, models.Error("unauthorized"))
 
 			return
 		}
-		_, err = jwt.VerifyToken(log, secret, req.Token)
+		email := principal.Email
+
+		filter, err := parseFilter(r.URL.Query())
 		if err != nil {
-			log.Error("failed to verify token", sl.Err(err))
+			log.Info("invalid query", sl.Err(err))
 
-			render.Status(r, http.StatusUnauthorized)
+			render.Status(r, http.StatusBadRequest)
 
-			render.JSON(w, r, models.Error("invalid token"))
+			render.JSON(w, r, models.Error(err.Error()))
 
 			return
 		}
-		userPost, err := byIDGetter.GetAll(r.Context())
-		if err != nil {
-			if errors.Is(err, service.ErrUserNotFound) {
-				log.Warn("users not found", sl.Err(err))
-
-				render.Status(r, http.StatusNotFound)
 
-				render.JSON(w, r, models.Error("invalid credentials"))
-
-				return
-			}
-			log.Error("failed to get users", sl.Err(err))
+		userPost, next, err := byIDGetter.GetAll(r.Context(), email, filter)
+		if err != nil {
+			log.Error("failed to get posts", sl.Err(err))
 
 			render.Status(r, http.StatusInternalServerError)
 
-			render.JSON(w, r, models.Error("failed to get user"))
+			render.JSON(w, r, models.Error("failed to get posts"))
 
 			return
 		}
 
+		views := make([]models.PostView, 0, len(userPost))
+		for _, post := range userPost {
+			views = append(views, post.View())
+		}
+
 		render.JSON(w, r, Response{
-			UsersInfo: userPost,
-			Response:  models.OK(),
+			UsersInfo:   views,
+			NextAfterID: next,
+			Response:    models.OK(),
 		})
 	}
 }
+
+func parseFilter(q url.Values) (models.PostFilter, error) {
+	filter := models.PostFilter{
+		Email: q.Get("email"),
+		Limit: defaultLimit,
+	}
+
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxLimit {
+			return models.PostFilter{}, errors.New("invalid limit")
+		}
+		filter.Limit = limit
+	}
+
+	if v := q.Get("after_id"); v != "" {
+		afterID, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || afterID < 0 {
+			return models.PostFilter{}, errors.New("invalid after_id")
+		}
+		filter.AfterID = afterID
+	}
+
+	for name, dst := range map[string]**time.Time{
+		"created_from": &filter.CreatedFrom,
+		"created_to":   &filter.CreatedTo,
+	} {
+		v := q.Get(name)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return models.PostFilter{}, errors.New("invalid " + name)
+		}
+		*dst = &t
+	}
+
+	return filter, nil
+}
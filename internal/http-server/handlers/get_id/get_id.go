@@ -3,42 +3,39 @@ package get_id
 import (
 	"context"
 	"errors"
-	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/render"
-	"github.com/maestro-milagro/Post_Service_PB/internal/lib/jwt"
+	"github.com/maestro-milagro/Post_Service_PB/internal/http-server/access"
+	"github.com/maestro-milagro/Post_Service_PB/internal/lib/auth"
 	"github.com/maestro-milagro/Post_Service_PB/internal/lib/sl"
 	"github.com/maestro-milagro/Post_Service_PB/internal/models"
-	"github.com/maestro-milagro/Post_Service_PB/internal/storage"
-	"io"
+	"github.com/maestro-milagro/Post_Service_PB/internal/service"
 	"log/slog"
 	"net/http"
-	"strconv"
 )
 
-type Request struct {
-	Token string `json:"token"`
-}
-
 type Response struct {
-	FileName string `json:"file_name"`
-	File     []byte `json:"file"`
+	FileName string          `json:"file_name"`
+	File     []byte          `json:"file"`
+	Post     models.PostView `json:"post"`
+	Lineage  models.Lineage  `json:"lineage"`
 	models.Response
 }
 
-type ByIDGetter interface {
-	GetById(ctx context.Context, id int) (models.PostUser, error)
-}
-
-type CloudDownloader interface {
-	DownloadFile(bucketName string, filename string) ([]byte, error)
+// PostOpener resolves the post either by its public slug or, for the owner, by id,
+// and checks that the viewer may read it.
+type PostOpener interface {
+	Open(ctx context.Context, viewer string, ref service.PostRef, password string) (models.PostUser, func(), error)
+	Lineage(ctx context.Context, viewer string, post models.PostUser) (models.Lineage, error)
 }
 
+// New returns the post with its content inline. Posts larger than maxSize are
+// refused with 413 and have to be downloaded from the raw endpoint.
 func New(log *slog.Logger,
-	secret string,
 	bucketName string,
-	cloud CloudDownloader,
-	byIDGetter ByIDGetter,
+	maxSize int64,
+	cloud *service.Content,
+	opener PostOpener,
 ) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "handlers.get_id.New"
@@ -48,78 +45,72 @@ func New(log *slog.Logger,
 			slog.String("request_id", middleware.GetReqID(r.Context())),
 		)
 
-		var req Request
+		principal, ok := auth.FromContext(r.Context())
+		if !ok {
+			log.Error("request is not authenticated")
 
-		err := render.DecodeJSON(r.Body, &req)
-		if errors.Is(err, io.EOF) {
-			log.Error("request body is empty")
-
-			render.Status(r, http.StatusBadRequest)
+			render.Status(r, http.StatusUnauthorized)
 
-			render.JSON(w, r, models.Error("empty request"))
+			render.JSON(w, r, models.Error("unauthorized"))
 
 			return
 		}
-		_, err = jwt.VerifyToken(log, secret, req.Token)
-		if err != nil {
-			log.Error("failed to verify token", sl.Err(err))
+		email := principal.Email
 
-			render.Status(r, http.StatusUnauthorized)
+		userPost, release, err := opener.Open(r.Context(), email, access.Ref(r), access.Password(r))
+		if err != nil {
+			log.Info("post cannot be read", sl.Err(err))
 
-			render.JSON(w, r, models.Error("invalid token"))
+			access.SetRetryAfter(w, err)
 
-			return
-		}
+			status, msg := access.Status(err, "failed to get post")
 
-		id := chi.URLParam(r, "id")
-		if id == "" {
-			log.Info("id is empty")
+			render.Status(r, status)
 
-			render.Status(r, http.StatusBadRequest)
-
-			render.JSON(w, r, models.Error("invalid request"))
+			render.JSON(w, r, models.Error(msg))
 
 			return
 		}
-		newId, err := strconv.Atoi(id)
-		if err != nil {
-			log.Error("failed to parse id", sl.Err(err))
-		}
-		userPost, err := byIDGetter.GetById(r.Context(), newId)
+
+		file, err := cloud.ReadPost(r.Context(), bucketName, userPost, maxSize)
 		if err != nil {
-			if errors.Is(err, storage.ErrUserNotFound) {
-				log.Warn("user not found", sl.Err(err))
+			release()
+
+			if errors.Is(err, service.ErrPostTooLarge) {
+				log.Info("post too large to return inline", slog.Int64("size", userPost.Size))
 
-				render.Status(r, http.StatusNotFound)
+				render.Status(r, http.StatusRequestEntityTooLarge)
 
-				render.JSON(w, r, models.Error("invalid credentials"))
+				render.JSON(w, r, models.Error("post too large, download it from /raw"))
 
 				return
 			}
-			log.Error("failed to get user", sl.Err(err))
+			log.Error("failed to download file", sl.Err(err))
 
 			render.Status(r, http.StatusInternalServerError)
 
-			render.JSON(w, r, models.Error("failed to get user"))
+			render.JSON(w, r, models.Error("failed to download file"))
 
 			return
 		}
 
-		// TODO: aws download
-		file, err := cloud.DownloadFile(bucketName, userPost.Key)
-		if err != nil {
-			log.Error("failed to download file", sl.Err(err))
-
-			render.Status(r, http.StatusInternalServerError)
-
-			render.JSON(w, r, models.Error("failed to download file"))
+		if userPost.BurnAfterRead {
+			if err := cloud.DeleteObjects(context.WithoutCancel(r.Context()), bucketName, []string{userPost.Key}); err != nil {
+				log.Error("failed to delete burnt object", sl.Err(err), slog.String("key", userPost.Key))
+			}
+		}
 
-			return
+		// Пост уже прочитан (а одноразовый ещё и «сожжён»), поэтому без родословной отдаём его как есть
+		lineage, err := opener.Lineage(r.Context(), email, userPost)
+		if err != nil {
+			log.Error("failed to get lineage", sl.Err(err))
 		}
 
 		render.JSON(w, r, Response{
-			FileName: userPost.Key,
+			FileName: userPost.FileName,
 			File:     file,
+			Post:     userPost.View(),
+			Lineage:  lineage,
 			Response: models.OK(),
 		})
 	}
@@ -0,0 +1,163 @@
+package html
+
+import (
+	"context"
+	"errors"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/maestro-milagro/Post_Service_PB/internal/http-server/access"
+	"github.com/maestro-milagro/Post_Service_PB/internal/lib/auth"
+	"github.com/maestro-milagro/Post_Service_PB/internal/lib/diff"
+	"github.com/maestro-milagro/Post_Service_PB/internal/lib/highlight"
+	"github.com/maestro-milagro/Post_Service_PB/internal/lib/sl"
+	"github.com/maestro-milagro/Post_Service_PB/internal/models"
+	"github.com/maestro-milagro/Post_Service_PB/internal/service"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+var (
+	errBinary   = errors.New("binary content cannot be highlighted")
+	errTooLarge = errors.New("content too large to highlight")
+)
+
+// PostOpener resolves the post either by its public slug or, for the owner, by id,
+// and checks that the viewer may read it.
+type PostOpener interface {
+	Open(ctx context.Context, viewer string, ref service.PostRef, password string) (models.PostUser, func(), error)
+}
+
+// New renders a text post as a syntax-highlighted HTML page with numbered,
+// linkable lines (#L12).
+func New(log *slog.Logger,
+	bucketName string,
+	maxSize int64,
+	cloud *service.Content,
+	renderer *highlight.Renderer,
+	opener PostOpener,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.html.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		principal, ok := auth.FromContext(r.Context())
+		if !ok {
+			log.Error("request is not authenticated")
+
+			render.Status(r, http.StatusUnauthorized)
+
+			render.JSON(w, r, models.Error("unauthorized"))
+
+			return
+		}
+		email := principal.Email
+
+		userPost, release, err := opener.Open(r.Context(), email, access.Ref(r), access.Password(r))
+		if err == nil {
+			if err = renderable(userPost, maxSize); err != nil {
+				release()
+			}
+		}
+		if err != nil {
+			log.Info("post cannot be rendered", sl.Err(err))
+
+			access.SetRetryAfter(w, err)
+
+			status, msg := errorStatus(err)
+
+			render.Status(r, status)
+
+			render.JSON(w, r, models.Error(msg))
+
+			return
+		}
+
+		page, err := highlighted(r.Context(), cloud, renderer, bucketName, userPost, maxSize)
+		if err != nil {
+			log.Error("failed to render post", sl.Err(err))
+			release()
+
+			status, msg := errorStatus(err)
+
+			render.Status(r, status)
+
+			render.JSON(w, r, models.Error(msg))
+
+			return
+		}
+
+		header := w.Header()
+		header.Set("Content-Type", "text/html; charset=utf-8")
+		header.Set("Content-Length", strconv.Itoa(len(page)))
+		// Подсветка задаётся inline-стилями, всё остальное запрещаем
+		header.Set("X-Content-Type-Options", "nosniff")
+		header.Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; sandbox")
+		w.WriteHeader(http.StatusOK)
+
+		if _, err := w.Write(page); err != nil {
+			log.Warn("failed to write page", sl.Err(err))
+			release()
+
+			return
+		}
+
+		if userPost.BurnAfterRead {
+			if err := cloud.DeleteObjects(context.WithoutCancel(r.Context()), bucketName, []string{userPost.Key}); err != nil {
+				log.Error("failed to delete burnt object", sl.Err(err), slog.String("key", userPost.Key))
+			}
+		}
+	}
+}
+
+// renderable rejects the posts that are known not to render before anything is read.
+func renderable(post models.PostUser, maxSize int64) error {
+	// Содержимое, зашифрованное клиентом, сервис не читает
+	if post.ClientEncrypted {
+		return errBinary
+	}
+	if post.Size > maxSize {
+		return errTooLarge
+	}
+	return nil
+}
+
+func highlighted(ctx context.Context, cloud *service.Content, renderer *highlight.Renderer,
+	bucketName string, post models.PostUser, maxSize int64) ([]byte, error) {
+	data, err := cloud.ReadPost(ctx, bucketName, post, maxSize)
+	if errors.Is(err, service.ErrPostTooLarge) {
+		return nil, errTooLarge
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !diff.IsText(data) {
+		return nil, errBinary
+	}
+
+	return renderer.Render(cacheChecksum(post), post.FileName, post.Syntax, string(data))
+}
+
+// cacheChecksum is the checksum the page is cached by, empty when it must not be cached.
+func cacheChecksum(post models.PostUser) string {
+	// Страница одноразового или защищённого паролем поста не должна переживать запрос:
+	// из кэша её содержимое осталось бы в памяти процесса после «сжигания» или смены пароля
+	if post.BurnAfterRead || post.Protected() {
+		return ""
+	}
+	return post.Checksum
+}
+
+func errorStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, errBinary):
+		return http.StatusUnsupportedMediaType, err.Error()
+	case errors.Is(err, errTooLarge):
+		return http.StatusRequestEntityTooLarge, err.Error()
+	}
+	return access.Status(err, "failed to render post")
+}
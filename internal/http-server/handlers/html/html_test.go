@@ -0,0 +1,139 @@
+package html
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/maestro-milagro/Post_Service_PB/internal/lib/auth"
+	"github.com/maestro-milagro/Post_Service_PB/internal/lib/highlight"
+	"github.com/maestro-milagro/Post_Service_PB/internal/models"
+	"github.com/maestro-milagro/Post_Service_PB/internal/service"
+	"github.com/maestro-milagro/Post_Service_PB/internal/service/envelope"
+	"github.com/maestro-milagro/Post_Service_PB/internal/service/localfs"
+)
+
+const bucket = "posts"
+
+// opener hands out its post to everyone and counts the releases.
+type opener struct {
+	post     models.PostUser
+	released int
+}
+
+func (o *opener) Open(context.Context, string, service.PostRef, string) (models.PostUser, func(), error) {
+	return o.post, func() { o.released++ }, nil
+}
+
+func newContent(t *testing.T, log *slog.Logger) *service.Content {
+	t.Helper()
+
+	store, err := localfs.New(log, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyring, err := envelope.NewKeyring("", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return service.NewContent(store, keyring)
+}
+
+func TestNew(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	tests := []struct {
+		name   string
+		post   models.PostUser
+		cached bool
+	}{
+		{"plain", models.PostUser{}, true},
+		{"burn after read", models.PostUser{BurnAfterRead: true}, false},
+		{"protected", models.PostUser{PasswordHash: []byte("hash")}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cloud := newContent(t, log)
+			ctx := context.Background()
+
+			post := tt.post
+			post.Key, post.FileName, post.Checksum = "author/1", "main.txt", "checksum"
+			o := &opener{post: post}
+			handler := New(log, bucket, 1<<20, cloud, highlight.New("github", 1<<20), o)
+
+			serve := func(text string) *httptest.ResponseRecorder {
+				t.Helper()
+
+				// Содержимое меняется, а контрольная сумма поста нет: так видно, пришла ли страница из кэша
+				if _, err := cloud.UploadPost(ctx, bucket, post.Key, strings.NewReader(text), "text/plain"); err != nil {
+					t.Fatal(err)
+				}
+				o.post.Size = int64(len(text))
+
+				r := httptest.NewRequest(http.MethodGet, "/", nil)
+				r = r.WithContext(auth.NewContext(r.Context(), auth.Principal{Email: "reader@example.com"}))
+				w := httptest.NewRecorder()
+
+				handler.ServeHTTP(w, r)
+
+				if w.Code != http.StatusOK {
+					t.Fatalf("status %d: %s", w.Code, w.Body)
+				}
+				return w
+			}
+
+			w := serve("first version")
+
+			header := w.Header()
+			if got := header.Get("Content-Type"); got != "text/html; charset=utf-8" {
+				t.Errorf("Content-Type %q", got)
+			}
+			if got := header.Get("X-Content-Type-Options"); got != "nosniff" {
+				t.Errorf("X-Content-Type-Options %q", got)
+			}
+			if got := header.Get("Content-Security-Policy"); got != "default-src 'none'; style-src 'unsafe-inline'; sandbox" {
+				t.Errorf("Content-Security-Policy %q", got)
+			}
+			if !strings.Contains(w.Body.String(), "first") {
+				t.Fatal("page does not contain the post")
+			}
+
+			if post.BurnAfterRead {
+				if _, err := cloud.StatPost(ctx, bucket, post); err == nil {
+					t.Error("burnt object was not deleted")
+				}
+			}
+
+			w = serve("second version")
+			if cached := strings.Contains(w.Body.String(), "first"); cached != tt.cached {
+				t.Errorf("page cached: %v, want %v", cached, tt.cached)
+			}
+			if o.released != 0 {
+				t.Errorf("post released %d times after a successful read", o.released)
+			}
+		})
+	}
+}
+
+func TestCacheChecksum(t *testing.T) {
+	tests := []struct {
+		name string
+		post models.PostUser
+		want string
+	}{
+		{"plain", models.PostUser{Checksum: "abc"}, "abc"},
+		{"burn after read", models.PostUser{Checksum: "abc", BurnAfterRead: true}, ""},
+		{"protected", models.PostUser{Checksum: "abc", PasswordHash: []byte("hash")}, ""},
+	}
+
+	for _, tt := range tests {
+		if got := cacheChecksum(tt.post); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -2,145 +2,353 @@ package post
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/render"
-	"github.com/maestro-milagro/Post_Service_PB/internal/lib/jwt"
+	"github.com/maestro-milagro/Post_Service_PB/internal/lib/auth"
+	"github.com/maestro-milagro/Post_Service_PB/internal/lib/highlight"
+	"github.com/maestro-milagro/Post_Service_PB/internal/lib/objkey"
 	"github.com/maestro-milagro/Post_Service_PB/internal/lib/sl"
+	"github.com/maestro-milagro/Post_Service_PB/internal/lib/upload"
 	"github.com/maestro-milagro/Post_Service_PB/internal/models"
-	"io"
+	"github.com/maestro-milagro/Post_Service_PB/internal/service"
 	"log/slog"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 type Request struct {
-	Token string `json:"token"`
+	Title string `json:"title"`
+	// ExpiresIn is a duration ("90m", "24h") or a number of seconds; ExpiresAt is RFC 3339.
+	ExpiresIn     string `json:"expires_in"`
+	ExpiresAt     string `json:"expires_at"`
+	BurnAfterRead bool   `json:"burn_after_read"`
+	// Visibility is one of public (default), unlisted, followers or private.
+	Visibility string `json:"visibility"`
+	// Password, when set, has to be sent in the X-Paste-Password header to download the post.
+	Password string `json:"-"`
+	// ClientEncrypted marks content encrypted by the client; it is stored as opaque bytes.
+	ClientEncrypted bool `json:"client_encrypted"`
+	// Syntax names the language for highlighting, detected from the file when empty.
+	Syntax string `json:"syntax"`
 }
 
 type Response struct {
-	Id int `json:"id"`
+	Id   int    `json:"id"`
+	Slug string `json:"slug"`
 	models.Response
 }
 
 type PostUserSaver interface {
-	SavePost(ctx context.Context, user models.PostUser) (int64, error)
-}
-
-type CloudUploader interface {
-	UploadFile(bucketName string, fileName string, largeObject []byte) error
-}
-
-type Producer interface {
-	Produce(post models.Post, topic string)
+	SavePost(ctx context.Context, user models.PostUser) (int64, string, error)
 }
 
+// New accepts a multipart form and streams its file part to the blob store without
+// buffering it. Without an Authorization header the "token" field must precede the file,
+// the auth middleware reads it from there.
 func New(log *slog.Logger,
 	bucket string,
-	secret string,
+	maxUploadSize int64,
+	maxTTL time.Duration,
+	transferTimeout time.Duration,
 	postUserSaver PostUserSaver,
-	uploader CloudUploader,
-	producer Producer,
+	uploader *service.Content,
 ) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		const op = "handlers.save.New"
+		const op = "handlers.post.New"
 
 		log := log.With(
 			slog.String("op", op),
 			slog.String("request_id", middleware.GetReqID(r.Context())),
 		)
 
-		var req Request
+		principal, ok := auth.FromContext(r.Context())
+		if !ok {
+			log.Error("request is not authenticated")
+
+			render.Status(r, http.StatusUnauthorized)
+
+			render.JSON(w, r, models.Error("unauthorized"))
 
-		err := r.ParseMultipartForm(100)
+			return
+		}
+		email := principal.Email
+
+		// Таймаут сервера рассчитан на короткие запросы, большие файлы загружаются дольше
+		if err := http.NewResponseController(w).SetReadDeadline(time.Now().Add(transferTimeout)); err != nil {
+			log.Warn("failed to extend read deadline", sl.Err(err))
+		}
+
+		form := upload.NewForm(w, r, maxUploadSize)
+
+		part, err := form.NextFile()
 		if err != nil {
-			log.Error("failed to decode request body", sl.Err(err))
+			log.Error("failed to read form", sl.Err(err))
+
+			status, msg := upload.Status(err, "failed to upload file")
+
+			render.Status(r, status)
+
+			render.JSON(w, r, models.Error(msg))
+
+			return
+		}
+
+		fileName := part.FileName()
+
+		key, err := objkey.New(email)
+		if err != nil {
+			log.Error("failed to generate object key", sl.Err(err))
 
 			render.Status(r, http.StatusInternalServerError)
 
-			render.JSON(w, r, models.Error("failed to decode request"))
+			render.JSON(w, r, models.Error("failed to upload file"))
 
 			return
 		}
-		mForm := r.MultipartForm
 
-		req.Token = mForm.Value["token"][0]
-		if req.Token == "" {
-			log.Error("token is empty")
+		opaque, _ := strconv.ParseBool(form.Value("client_encrypted"))
+		body, err := form.Open(part, opaque)
+		if err != nil {
+			log.Error("failed to read file", sl.Err(err), slog.Int64("limit", maxUploadSize))
+
+			status, msg := upload.Status(err, "failed to upload file")
 
-			render.Status(r, http.StatusBadRequest)
+			render.Status(r, status)
+
+			render.JSON(w, r, models.Error(msg))
 
-			render.JSON(w, r, models.Error("empty token"))
 			return
 		}
 
-		var key string
-		var fileObject []byte
+		sealing, err := uploader.UploadPost(r.Context(), bucket, key, body, body.ContentType())
+		if err != nil {
+			log.Error("failed to upload file", sl.Err(err), slog.Int64("limit", maxUploadSize))
+
+			status, msg := upload.Status(body.Cause(err), "failed to upload file")
+
+			render.Status(r, status)
+
+			render.JSON(w, r, models.Error(msg))
+
+			return
+		}
+
+		// Пост не будет сохранён, поэтому загруженный объект никому не принадлежит
+		discard := func() {
+			if err := uploader.DeleteObjects(context.WithoutCancel(r.Context()), bucket, []string{key}); err != nil {
+				log.Error("failed to delete orphaned object", sl.Err(err), slog.String("key", key))
+			}
+		}
+
+		// Поля могут идти и после файла
+		if err := form.Rest(); err != nil {
+			log.Error("failed to read form", sl.Err(err))
+			discard()
+
+			status, msg := upload.Status(err, "failed to save post")
+
+			render.Status(r, status)
+
+			render.JSON(w, r, models.Error(msg))
+
+			return
+		}
+
+		req := Request{
+			Title:      form.Value("title"),
+			ExpiresIn:  form.Value("expires_in"),
+			ExpiresAt:  form.Value("expires_at"),
+			Visibility: form.Value("visibility"),
+			Password:   form.Value("password"),
+			Syntax:     form.Value("syntax"),
+		}
+		burnAfterRead, clientEncrypted := form.Value("burn_after_read"), form.Value("client_encrypted")
+
+		expiresAt, err := parseExpiry(req, time.Now(), maxTTL)
+		if err != nil {
+			log.Info("invalid expiration", sl.Err(err))
+			discard()
+
+			render.Status(r, http.StatusBadRequest)
+
+			render.JSON(w, r, models.Error(err.Error()))
+
+			return
+		}
 
-		for k, _ := range mForm.File {
-			// k is the key of file part
-			file, fileHeader, err := r.FormFile(k)
+		if clientEncrypted != "" {
+			req.ClientEncrypted, err = strconv.ParseBool(clientEncrypted)
 			if err != nil {
-				log.Error("invoke FormFile error:")
+				log.Info("invalid client_encrypted", sl.Err(err))
+				discard()
 
 				render.Status(r, http.StatusBadRequest)
 
-				render.JSON(w, r, models.Error("bed request"))
+				render.JSON(w, r, models.Error("invalid client_encrypted"))
 
 				return
 			}
-			key = fileHeader.Filename
-			fileObject, err = io.ReadAll(file)
+		}
+
+		contentType := body.ContentType()
+		if req.ClientEncrypted {
+			// Флаг мог прийти после файла: результат распознавания типа отбрасываем
+			contentType = upload.OpaqueContentType
+		}
+
+		if burnAfterRead != "" {
+			req.BurnAfterRead, err = strconv.ParseBool(burnAfterRead)
 			if err != nil {
-				log.Error("invoke ReadAll error:")
+				log.Info("invalid burn_after_read", sl.Err(err))
+				discard()
 
-				render.Status(r, http.StatusInternalServerError)
+				render.Status(r, http.StatusBadRequest)
 
-				render.JSON(w, r, models.Error("error while reading file"))
+				render.JSON(w, r, models.Error("invalid burn_after_read"))
+
+				return
 			}
 		}
 
-		log.Info("request body decoded", slog.Any("request", req))
+		if req.Visibility == "" {
+			req.Visibility = models.VisibilityPublic
+		}
+		if !models.ValidVisibility(req.Visibility) {
+			log.Info("invalid visibility", slog.String("visibility", req.Visibility))
+			discard()
 
-		email, err := jwt.VerifyToken(log, secret, req.Token)
-		if err != nil {
-			log.Error("failed to verify token", sl.Err(err))
+			render.Status(r, http.StatusBadRequest)
 
-			render.Status(r, http.StatusUnauthorized)
+			render.JSON(w, r, models.Error("invalid visibility"))
 
-			render.JSON(w, r, models.Error("invalid token"))
+			return
 		}
 
-		//TODO: AWS integration
-		err = uploader.UploadFile(bucket, key, fileObject)
-		if err != nil {
-			log.Error("failed to upload file", sl.Err(err))
+		if req.Syntax != "" {
+			req.Syntax, err = highlight.Syntax(req.Syntax)
+			if err != nil {
+				log.Info("invalid syntax", sl.Err(err))
+				discard()
 
-			render.Status(r, http.StatusInternalServerError)
+				render.Status(r, http.StatusBadRequest)
 
-			render.JSON(w, r, models.Error("failed to upload file"))
+				render.JSON(w, r, models.Error("unknown syntax"))
+
+				return
+			}
 		}
 
-		fmt.Println(fileObject)
+		var passwordHash []byte
+		if req.Password != "" {
+			passwordHash, err = service.HashPassword(req.Password)
+			if err != nil {
+				log.Info("failed to hash password", sl.Err(err))
+				discard()
+
+				if errors.Is(err, service.ErrPasswordTooLong) {
+					render.Status(r, http.StatusBadRequest)
+
+					render.JSON(w, r, models.Error(err.Error()))
+
+					return
+				}
+				render.Status(r, http.StatusInternalServerError)
 
-		id, err := postUserSaver.SavePost(r.Context(), models.PostUser{
-			Email:  email,
-			Bucket: bucket,
-			Key:    key,
+				render.JSON(w, r, models.Error("failed to save post"))
+
+				return
+			}
+		}
+
+		if req.Title == "" {
+			req.Title = fileName
+		}
+
+		id, postSlug, err := postUserSaver.SavePost(r.Context(), models.PostUser{
+			Email:           email,
+			Bucket:          bucket,
+			Key:             key,
+			Title:           req.Title,
+			FileName:        fileName,
+			ContentType:     contentType,
+			Size:            body.Size(),
+			Checksum:        body.Checksum(),
+			ExpiresAt:       expiresAt,
+			BurnAfterRead:   req.BurnAfterRead,
+			Visibility:      req.Visibility,
+			PasswordHash:    passwordHash,
+			EncKeyID:        sealing.KeyID,
+			EncDataKey:      sealing.DataKey,
+			ClientEncrypted: req.ClientEncrypted,
+			Syntax:          req.Syntax,
 		})
 		if err != nil {
 			log.Error("failed to save post", sl.Err(err))
+			discard()
 
 			render.Status(r, http.StatusInternalServerError)
 
 			render.JSON(w, r, models.Error("failed to save post"))
-		}
 
-		// TODO: notification service
-		producer.Produce(models.Post{PostID: int(id), Email: email}, "posts")
+			return
+		}
 
 		render.JSON(w, r, Response{
 			Id:       int(id),
+			Slug:     postSlug,
 			Response: models.OK(),
 		})
 	}
 }
+
+// parseExpiry turns the optional expires_in/expires_at fields into an absolute time
+// at most maxTTL ahead; a zero maxTTL leaves the lifetime of posts unbounded.
+func parseExpiry(req Request, now time.Time, maxTTL time.Duration) (*time.Time, error) {
+	if maxTTL <= 0 {
+		maxTTL = math.MaxInt64
+	}
+
+	var expiresAt time.Time
+
+	switch {
+	case req.ExpiresIn != "" && req.ExpiresAt != "":
+		return nil, errors.New("expires_in and expires_at are mutually exclusive")
+	case req.ExpiresIn != "":
+		ttl, err := time.ParseDuration(req.ExpiresIn)
+		if err != nil {
+			seconds, convErr := strconv.ParseInt(req.ExpiresIn, 10, 64)
+			if convErr != nil {
+				return nil, errors.New("invalid expires_in")
+			}
+			// Проверяем до умножения: большое число секунд переполнит Duration
+			if seconds <= 0 {
+				return nil, errors.New("expiration must be in the future")
+			}
+			if seconds > int64(maxTTL/time.Second) {
+				return nil, fmt.Errorf("expiration must be within %s", maxTTL)
+			}
+			ttl = time.Duration(seconds) * time.Second
+		}
+		expiresAt = now.Add(ttl)
+	case req.ExpiresAt != "":
+		t, err := time.Parse(time.RFC3339, req.ExpiresAt)
+		if err != nil {
+			return nil, errors.New("invalid expires_at")
+		}
+		expiresAt = t
+	default:
+		return nil, nil
+	}
+
+	if !expiresAt.After(now) {
+		return nil, errors.New("expiration must be in the future")
+	}
+	if expiresAt.Sub(now) > maxTTL {
+		return nil, fmt.Errorf("expiration must be within %s", maxTTL)
+	}
+	return &expiresAt, nil
+}
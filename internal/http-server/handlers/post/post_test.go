@@ -0,0 +1,57 @@
+package post
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseExpiry(t *testing.T) {
+	now := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+	const maxTTL = 365 * 24 * time.Hour
+
+	tests := []struct {
+		name string
+		req  Request
+		want time.Duration
+		err  bool
+	}{
+		{name: "none", req: Request{}},
+		{name: "duration", req: Request{ExpiresIn: "90m"}, want: 90 * time.Minute},
+		{name: "seconds", req: Request{ExpiresIn: "3600"}, want: time.Hour},
+		{name: "absolute", req: Request{ExpiresAt: "2024-07-02T12:00:00Z"}, want: 24 * time.Hour},
+		{name: "both", req: Request{ExpiresIn: "1h", ExpiresAt: "2024-07-02T12:00:00Z"}, err: true},
+		{name: "garbage", req: Request{ExpiresIn: "soon"}, err: true},
+		{name: "zero seconds", req: Request{ExpiresIn: "0"}, err: true},
+		{name: "negative seconds", req: Request{ExpiresIn: "-9223372036854775807"}, err: true},
+		{name: "past", req: Request{ExpiresAt: "2024-06-30T12:00:00Z"}, err: true},
+		{name: "max ttl", req: Request{ExpiresIn: "8760h"}, want: maxTTL},
+		{name: "over max ttl", req: Request{ExpiresIn: "8761h"}, err: true},
+		// Без проверки до умножения это число переполнило бы Duration и дало бы близкий срок
+		{name: "overflowing seconds", req: Request{ExpiresIn: "9223372036854775807"}, err: true},
+		{name: "far absolute", req: Request{ExpiresAt: "9999-12-31T23:59:59Z"}, err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseExpiry(tt.req, now, maxTTL)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.want == 0 {
+				if got != nil {
+					t.Fatalf("expected no expiration, got %v", got)
+				}
+				return
+			}
+			if got == nil || got.Sub(now) != tt.want {
+				t.Fatalf("got %v, want now+%s", got, tt.want)
+			}
+		})
+	}
+}
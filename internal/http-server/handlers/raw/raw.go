@@ -0,0 +1,190 @@
+package raw
+
+import (
+	"context"
+	"errors"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/maestro-milagro/Post_Service_PB/internal/http-server/access"
+	"github.com/maestro-milagro/Post_Service_PB/internal/lib/auth"
+	"github.com/maestro-milagro/Post_Service_PB/internal/lib/httprange"
+	"github.com/maestro-milagro/Post_Service_PB/internal/lib/sl"
+	"github.com/maestro-milagro/Post_Service_PB/internal/models"
+	"github.com/maestro-milagro/Post_Service_PB/internal/service"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+)
+
+// PostOpener resolves the post either by its public slug or, for the owner, by id,
+// and checks that the viewer may read it.
+type PostOpener interface {
+	Open(ctx context.Context, viewer string, ref service.PostRef, password string) (models.PostUser, func(), error)
+}
+
+// New streams the post content as is, honouring conditional and single Range requests.
+func New(log *slog.Logger,
+	bucketName string,
+	transferTimeout time.Duration,
+	cloud *service.Content,
+	opener PostOpener,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.raw.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		principal, ok := auth.FromContext(r.Context())
+		if !ok {
+			log.Error("request is not authenticated")
+
+			render.Status(r, http.StatusUnauthorized)
+
+			render.JSON(w, r, models.Error("unauthorized"))
+
+			return
+		}
+		email := principal.Email
+
+		userPost, release, err := opener.Open(r.Context(), email, access.Ref(r), access.Password(r))
+		if err != nil {
+			log.Info("post cannot be read", sl.Err(err))
+
+			access.SetRetryAfter(w, err)
+
+			status, msg := access.Status(err, "failed to get post")
+
+			render.Status(r, status)
+
+			render.JSON(w, r, models.Error(msg))
+
+			return
+		}
+
+		info, err := cloud.StatPost(r.Context(), bucketName, userPost)
+		if err != nil {
+			release()
+
+			if errors.Is(err, service.ErrObjectNotFound) {
+				log.Warn("object not found", sl.Err(err))
+
+				render.Status(r, http.StatusNotFound)
+
+				render.JSON(w, r, models.Error("post not found"))
+
+				return
+			}
+			log.Error("failed to stat object", sl.Err(err))
+
+			render.Status(r, http.StatusInternalServerError)
+
+			render.JSON(w, r, models.Error("failed to download file"))
+
+			return
+		}
+
+		fileName := userPost.FileName
+		if fileName == "" {
+			fileName = path.Base(userPost.Key)
+		}
+
+		header := w.Header()
+		header.Set("Accept-Ranges", "bytes")
+		header.Set("Content-Type", contentType(info, fileName))
+		disposition := "inline"
+		if userPost.ClientEncrypted {
+			// Шифротекст клиента показывать бессмысленно, только скачивать
+			disposition = "attachment"
+		}
+		header.Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": fileName}))
+		// Содержимое пользовательское: запрещаем браузеру угадывать тип и исполнять его
+		header.Set("X-Content-Type-Options", "nosniff")
+		header.Set("Content-Security-Policy", "default-src 'none'; sandbox")
+		if info.ETag != "" {
+			header.Set("ETag", info.ETag)
+		}
+		if !info.LastModified.IsZero() {
+			header.Set("Last-Modified", info.LastModified.UTC().Format(http.TimeFormat))
+		}
+
+		// Одноразовый пост отдаём только целиком и без 304, иначе чтение не будет «успешным»
+		if !userPost.BurnAfterRead && info.ETag != "" && r.Header.Get("If-None-Match") == info.ETag {
+			w.WriteHeader(http.StatusNotModified)
+
+			return
+		}
+
+		rangeHeader := r.Header.Get("Range")
+		if ifRange := r.Header.Get("If-Range"); userPost.BurnAfterRead || (ifRange != "" && ifRange != info.ETag) {
+			rangeHeader = ""
+		}
+
+		rng, partial, err := httprange.Parse(rangeHeader, info.Size)
+		if err != nil {
+			header.Set("Content-Range", "bytes */"+strconv.FormatInt(info.Size, 10))
+
+			http.Error(w, err.Error(), http.StatusRequestedRangeNotSatisfiable)
+
+			return
+		}
+		if !partial {
+			rng = httprange.Range{Start: 0, Length: info.Size}
+		}
+
+		body, err := cloud.OpenPost(r.Context(), bucketName, userPost, rng.Start, rng.Length)
+		if err != nil {
+			log.Error("failed to open object", sl.Err(err))
+			release()
+
+			render.Status(r, http.StatusInternalServerError)
+
+			render.JSON(w, r, models.Error("failed to download file"))
+
+			return
+		}
+		defer body.Close()
+
+		// Таймаут сервера рассчитан на короткие запросы, большие объекты передаются дольше
+		if err := http.NewResponseController(w).SetWriteDeadline(time.Now().Add(transferTimeout)); err != nil {
+			log.Warn("failed to extend write deadline", sl.Err(err))
+		}
+
+		header.Set("Content-Length", strconv.FormatInt(rng.Length, 10))
+		if partial {
+			header.Set("Content-Range", rng.ContentRange(info.Size))
+			w.WriteHeader(http.StatusPartialContent)
+		} else {
+			w.WriteHeader(http.StatusOK)
+		}
+
+		if _, err := io.Copy(w, body); err != nil {
+			log.Warn("failed to stream object", sl.Err(err))
+			release()
+
+			return
+		}
+
+		if userPost.BurnAfterRead {
+			if err := cloud.DeleteObjects(context.WithoutCancel(r.Context()), bucketName, []string{userPost.Key}); err != nil {
+				log.Error("failed to delete burnt object", sl.Err(err), slog.String("key", userPost.Key))
+			}
+		}
+	}
+}
+
+func contentType(info service.ObjectInfo, fileName string) string {
+	if info.ContentType != "" {
+		return info.ContentType
+	}
+	if ct := mime.TypeByExtension(path.Ext(fileName)); ct != "" {
+		return ct
+	}
+	return "application/octet-stream"
+}
@@ -0,0 +1,114 @@
+package restore
+
+import (
+	"context"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/maestro-milagro/Post_Service_PB/internal/lib/auth"
+	"github.com/maestro-milagro/Post_Service_PB/internal/lib/sl"
+	"github.com/maestro-milagro/Post_Service_PB/internal/models"
+	"github.com/maestro-milagro/Post_Service_PB/internal/service"
+	"github.com/maestro-milagro/Post_Service_PB/internal/storage"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+type Response struct {
+	Revision models.PostRevision `json:"revision"`
+	models.Response
+}
+
+type Restorer interface {
+	Restore(ctx context.Context, email string, id int, n int) (models.PostRevision, error)
+}
+
+// New makes the content of revision {n} current again by adding it as a new revision.
+func New(log *slog.Logger, restorer Restorer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.restore.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		principal, ok := auth.FromContext(r.Context())
+		if !ok {
+			log.Error("request is not authenticated")
+
+			render.Status(r, http.StatusUnauthorized)
+
+			render.JSON(w, r, models.Error("unauthorized"))
+
+			return
+		}
+		email := principal.Email
+
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			log.Info("invalid id", sl.Err(err))
+
+			render.Status(r, http.StatusBadRequest)
+
+			render.JSON(w, r, models.Error("invalid request"))
+
+			return
+		}
+		n, err := strconv.Atoi(chi.URLParam(r, "n"))
+		if err != nil {
+			log.Info("invalid revision", sl.Err(err))
+
+			render.Status(r, http.StatusBadRequest)
+
+			render.JSON(w, r, models.Error("invalid request"))
+
+			return
+		}
+
+		rev, err := restorer.Restore(r.Context(), email, id, n)
+		if err != nil {
+			switch {
+			case errors.Is(err, storage.ErrPostNotFound), errors.Is(err, service.ErrNoRevision):
+				log.Warn("revision not found", sl.Err(err))
+
+				render.Status(r, http.StatusNotFound)
+
+				render.JSON(w, r, models.Error("revision not found"))
+			case errors.Is(err, service.ErrNotOwner):
+				log.Warn("not the owner", sl.Err(err))
+
+				render.Status(r, http.StatusForbidden)
+
+				render.JSON(w, r, models.Error("only the author can edit the post"))
+			case errors.Is(err, service.ErrPostExpired), errors.Is(err, service.ErrPostConsumed):
+				log.Info("post gone", sl.Err(err))
+
+				render.Status(r, http.StatusGone)
+
+				render.JSON(w, r, models.Error(err.Error()))
+			case errors.Is(err, service.ErrNotEditable):
+				log.Info("post not editable", sl.Err(err))
+
+				render.Status(r, http.StatusConflict)
+
+				render.JSON(w, r, models.Error(err.Error()))
+			default:
+				log.Error("failed to restore revision", sl.Err(err))
+
+				render.Status(r, http.StatusInternalServerError)
+
+				render.JSON(w, r, models.Error("failed to restore revision"))
+			}
+
+			return
+		}
+
+		render.JSON(w, r, Response{
+			Revision: rev,
+			Response: models.OK(),
+		})
+	}
+}
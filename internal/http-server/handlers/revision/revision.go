@@ -0,0 +1,134 @@
+package revision
+
+import (
+	"context"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/maestro-milagro/Post_Service_PB/internal/lib/auth"
+	"github.com/maestro-milagro/Post_Service_PB/internal/lib/sl"
+	"github.com/maestro-milagro/Post_Service_PB/internal/models"
+	"github.com/maestro-milagro/Post_Service_PB/internal/service"
+	"github.com/maestro-milagro/Post_Service_PB/internal/storage"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+type Response struct {
+	FileName string              `json:"file_name"`
+	File     []byte              `json:"file"`
+	Revision models.PostRevision `json:"revision"`
+	models.Response
+}
+
+type RevisionGetter interface {
+	Revision(ctx context.Context, email string, id int, n int) (models.PostUser, models.PostRevision, error)
+}
+
+// New returns revision {n} of the post with its content, to the post owner.
+func New(log *slog.Logger,
+	bucketName string,
+	maxSize int64,
+	cloud *service.Content,
+	getter RevisionGetter,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.revision.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		principal, ok := auth.FromContext(r.Context())
+		if !ok {
+			log.Error("request is not authenticated")
+
+			render.Status(r, http.StatusUnauthorized)
+
+			render.JSON(w, r, models.Error("unauthorized"))
+
+			return
+		}
+		email := principal.Email
+
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			log.Info("invalid id", sl.Err(err))
+
+			render.Status(r, http.StatusBadRequest)
+
+			render.JSON(w, r, models.Error("invalid request"))
+
+			return
+		}
+		n, err := strconv.Atoi(chi.URLParam(r, "n"))
+		if err != nil {
+			log.Info("invalid revision", sl.Err(err))
+
+			render.Status(r, http.StatusBadRequest)
+
+			render.JSON(w, r, models.Error("invalid request"))
+
+			return
+		}
+
+		post, rev, err := getter.Revision(r.Context(), email, id, n)
+		if err != nil {
+			if errors.Is(err, storage.ErrPostNotFound) || errors.Is(err, service.ErrNoRevision) {
+				log.Warn("revision not found", sl.Err(err))
+
+				render.Status(r, http.StatusNotFound)
+
+				render.JSON(w, r, models.Error("revision not found"))
+
+				return
+			}
+			if errors.Is(err, service.ErrPostExpired) || errors.Is(err, service.ErrPostConsumed) {
+				log.Info("post gone", sl.Err(err))
+
+				render.Status(r, http.StatusGone)
+
+				render.JSON(w, r, models.Error(err.Error()))
+
+				return
+			}
+			log.Error("failed to get revision", sl.Err(err))
+
+			render.Status(r, http.StatusInternalServerError)
+
+			render.JSON(w, r, models.Error("failed to get revision"))
+
+			return
+		}
+
+		file, err := cloud.ReadPost(r.Context(), bucketName, post, maxSize)
+		if err != nil {
+			if errors.Is(err, service.ErrPostTooLarge) {
+				log.Info("revision too large to return inline", slog.Int64("size", post.Size))
+
+				render.Status(r, http.StatusRequestEntityTooLarge)
+
+				render.JSON(w, r, models.Error("revision too large"))
+
+				return
+			}
+			log.Error("failed to download file", sl.Err(err))
+
+			render.Status(r, http.StatusInternalServerError)
+
+			render.JSON(w, r, models.Error("failed to download file"))
+
+			return
+		}
+
+		render.JSON(w, r, Response{
+			FileName: rev.FileName,
+			File:     file,
+			Revision: rev,
+			Response: models.OK(),
+		})
+	}
+}
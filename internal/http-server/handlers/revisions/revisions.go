@@ -0,0 +1,95 @@
+package revisions
+
+import (
+	"context"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/maestro-milagro/Post_Service_PB/internal/lib/auth"
+	"github.com/maestro-milagro/Post_Service_PB/internal/lib/sl"
+	"github.com/maestro-milagro/Post_Service_PB/internal/models"
+	"github.com/maestro-milagro/Post_Service_PB/internal/service"
+	"github.com/maestro-milagro/Post_Service_PB/internal/storage"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+type Response struct {
+	Revisions []models.PostRevision `json:"revisions"`
+	models.Response
+}
+
+type Lister interface {
+	Revisions(ctx context.Context, email string, id int) ([]models.PostRevision, error)
+}
+
+// New lists the revision history of the post to its owner.
+func New(log *slog.Logger, lister Lister) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.revisions.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		principal, ok := auth.FromContext(r.Context())
+		if !ok {
+			log.Error("request is not authenticated")
+
+			render.Status(r, http.StatusUnauthorized)
+
+			render.JSON(w, r, models.Error("unauthorized"))
+
+			return
+		}
+		email := principal.Email
+
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			log.Info("invalid id", sl.Err(err))
+
+			render.Status(r, http.StatusBadRequest)
+
+			render.JSON(w, r, models.Error("invalid request"))
+
+			return
+		}
+
+		revs, err := lister.Revisions(r.Context(), email, id)
+		if err != nil {
+			if errors.Is(err, storage.ErrPostNotFound) {
+				log.Warn("post not found", sl.Err(err))
+
+				render.Status(r, http.StatusNotFound)
+
+				render.JSON(w, r, models.Error("post not found"))
+
+				return
+			}
+			if errors.Is(err, service.ErrPostExpired) || errors.Is(err, service.ErrPostConsumed) {
+				log.Info("post gone", sl.Err(err))
+
+				render.Status(r, http.StatusGone)
+
+				render.JSON(w, r, models.Error(err.Error()))
+
+				return
+			}
+			log.Error("failed to list revisions", sl.Err(err))
+
+			render.Status(r, http.StatusInternalServerError)
+
+			render.JSON(w, r, models.Error("failed to list revisions"))
+
+			return
+		}
+
+		render.JSON(w, r, Response{
+			Revisions: revs,
+			Response:  models.OK(),
+		})
+	}
+}
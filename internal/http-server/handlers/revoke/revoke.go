@@ -0,0 +1,162 @@
+package revoke
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/maestro-milagro/Post_Service_PB/internal/lib/jwt"
+	"github.com/maestro-milagro/Post_Service_PB/internal/lib/sl"
+	"github.com/maestro-milagro/Post_Service_PB/internal/models"
+	"github.com/maestro-milagro/Post_Service_PB/internal/service/revocation"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+var errInvalidToken = errors.New("invalid token")
+
+// Request names exactly one of: the leaked token itself as JWT, only its JTI
+// when the token is not at hand, or a user whose every token is revoked by Email.
+// The token is not sent as "token", which the auth middleware would take for
+// the caller's own credentials.
+type Request struct {
+	JWT   string `json:"jwt"`
+	JTI   string `json:"jti"`
+	Email string `json:"email"`
+}
+
+type Response struct {
+	RevokedBefore *time.Time `json:"revoked_before,omitempty"`
+	models.Response
+}
+
+type Revoker interface {
+	RevokeToken(ctx context.Context, claims jwt.Claims) error
+	RevokeJTI(ctx context.Context, jti string) error
+	RevokeUser(ctx context.Context, email string) (time.Time, error)
+}
+
+type Verifier interface {
+	Verify(token string) (jwt.Claims, error)
+}
+
+// New revokes JWTs before they expire. The route is for administrators only.
+func New(log *slog.Logger, verifier Verifier, revoker Revoker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.revoke.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		var req Request
+
+		err := render.DecodeJSON(r.Body, &req)
+		if errors.Is(err, io.EOF) {
+			log.Error("request body is empty")
+
+			render.Status(r, http.StatusBadRequest)
+
+			render.JSON(w, r, models.Error("empty request"))
+
+			return
+		}
+		if err != nil {
+			log.Error("failed to decode request body", sl.Err(err))
+
+			render.Status(r, http.StatusBadRequest)
+
+			render.JSON(w, r, models.Error("failed to decode request"))
+
+			return
+		}
+
+		set := 0
+		for _, v := range []string{req.JWT, req.JTI, req.Email} {
+			if v != "" {
+				set++
+			}
+		}
+		if set != 1 {
+			log.Info("not exactly one of jwt, jti and email is set")
+
+			render.Status(r, http.StatusBadRequest)
+
+			render.JSON(w, r, models.Error("exactly one of jwt, jti and email is required"))
+
+			return
+		}
+
+		if req.Email != "" {
+			before, err := revoker.RevokeUser(r.Context(), req.Email)
+			if err != nil {
+				log.Error("failed to revoke user tokens", sl.Err(err))
+
+				render.Status(r, http.StatusInternalServerError)
+
+				render.JSON(w, r, models.Error("failed to revoke tokens"))
+
+				return
+			}
+
+			render.JSON(w, r, Response{
+				RevokedBefore: &before,
+				Response:      models.OK(),
+			})
+
+			return
+		}
+
+		if req.JTI != "" {
+			err = revoker.RevokeJTI(r.Context(), req.JTI)
+		} else {
+			err = revokeJWT(r.Context(), verifier, revoker, req.JWT)
+		}
+		if err != nil {
+			if errors.Is(err, errInvalidToken) {
+				log.Info("token cannot be verified", sl.Err(err))
+
+				render.Status(r, http.StatusBadRequest)
+
+				render.JSON(w, r, models.Error("invalid token"))
+
+				return
+			}
+			if errors.Is(err, revocation.ErrNoJTI) {
+				log.Info("token has no jti", sl.Err(err))
+
+				render.Status(r, http.StatusBadRequest)
+
+				render.JSON(w, r, models.Error("token has no jti, revoke by email instead"))
+
+				return
+			}
+			log.Error("failed to revoke token", sl.Err(err))
+
+			render.Status(r, http.StatusInternalServerError)
+
+			render.JSON(w, r, models.Error("failed to revoke token"))
+
+			return
+		}
+
+		render.JSON(w, r, Response{Response: models.OK()})
+	}
+}
+
+// revokeJWT revokes the token until its own exp, which only a verified token can be trusted for.
+func revokeJWT(ctx context.Context, verifier Verifier, revoker Revoker, token string) error {
+	claims, err := verifier.Verify(token)
+	if errors.Is(err, jwt.ErrExpired) {
+		// Истёкший токен и так не примут
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %w", errInvalidToken, err)
+	}
+	return revoker.RevokeToken(ctx, claims)
+}
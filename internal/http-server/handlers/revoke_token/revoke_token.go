@@ -0,0 +1,75 @@
+package revoke_token
+
+import (
+	"context"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/maestro-milagro/Post_Service_PB/internal/lib/auth"
+	"github.com/maestro-milagro/Post_Service_PB/internal/lib/sl"
+	"github.com/maestro-milagro/Post_Service_PB/internal/models"
+	"github.com/maestro-milagro/Post_Service_PB/internal/service/tokens"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+type Revoker interface {
+	Revoke(ctx context.Context, email string, id int64) error
+}
+
+// New revokes the caller's API token {id}. Revoking a revoked token succeeds.
+func New(log *slog.Logger, revoker Revoker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.revoke_token.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		principal, ok := auth.FromContext(r.Context())
+		if !ok {
+			log.Error("request is not authenticated")
+
+			render.Status(r, http.StatusUnauthorized)
+
+			render.JSON(w, r, models.Error("unauthorized"))
+
+			return
+		}
+
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			log.Info("invalid id", sl.Err(err))
+
+			render.Status(r, http.StatusBadRequest)
+
+			render.JSON(w, r, models.Error("invalid request"))
+
+			return
+		}
+
+		if err := revoker.Revoke(r.Context(), principal.Email, id); err != nil {
+			if errors.Is(err, tokens.ErrNoToken) {
+				log.Info("token not found", sl.Err(err))
+
+				render.Status(r, http.StatusNotFound)
+
+				render.JSON(w, r, models.Error("token not found"))
+
+				return
+			}
+			log.Error("failed to revoke token", sl.Err(err))
+
+			render.Status(r, http.StatusInternalServerError)
+
+			render.JSON(w, r, models.Error("failed to revoke token"))
+
+			return
+		}
+
+		render.JSON(w, r, models.OK())
+	}
+}
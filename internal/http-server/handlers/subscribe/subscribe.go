@@ -5,17 +5,19 @@ import (
 	"errors"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/render"
+	"github.com/maestro-milagro/Post_Service_PB/internal/lib/auth"
 	"github.com/maestro-milagro/Post_Service_PB/internal/lib/sl"
 	"github.com/maestro-milagro/Post_Service_PB/internal/models"
 	"github.com/maestro-milagro/Post_Service_PB/internal/service"
 	"io"
 	"log/slog"
 	"net/http"
-	"strconv"
 )
 
+// Request names the author to follow. The follower is always the caller; the
+// sub_id field older clients send is ignored.
 type Request struct {
-	models.Subscriptions
+	UID int `json:"uid"`
 }
 
 type Response struct {
@@ -23,20 +25,32 @@ type Response struct {
 }
 
 type Subscriber interface {
-	Subscribe(ctx context.Context, uid int, subId int) error
+	Subscribe(ctx context.Context, email string, authorID int) error
 }
 
+// New subscribes the caller to the author uid. Subscribing again succeeds.
 func New(log *slog.Logger,
 	subscriber Subscriber,
 ) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		const op = "handlers.save.New"
+		const op = "handlers.subscribe.New"
 
 		log := log.With(
 			slog.String("op", op),
 			slog.String("request_id", middleware.GetReqID(r.Context())),
 		)
 
+		principal, ok := auth.FromContext(r.Context())
+		if !ok {
+			log.Error("request is not authenticated")
+
+			render.Status(r, http.StatusUnauthorized)
+
+			render.JSON(w, r, models.Error("unauthorized"))
+
+			return
+		}
+
 		var req Request
 
 		err := render.DecodeJSON(r.Body, &req)
@@ -46,35 +60,38 @@ func New(log *slog.Logger,
 			render.Status(r, http.StatusBadRequest)
 
 			render.JSON(w, r, models.Error("empty request"))
+
 			return
 		}
 		if err != nil {
 			log.Error("failed to decode request body", sl.Err(err))
 
-			render.Status(r, http.StatusInternalServerError)
+			render.Status(r, http.StatusBadRequest)
 
 			render.JSON(w, r, models.Error("failed to decode request"))
 
 			return
 		}
 
-		log.Info("request body decoded", slog.Any("request", req))
+		if req.UID < 1 {
+			log.Info("invalid uid", slog.Int("uid", req.UID))
 
-		err = subscriber.Subscribe(r.Context(), req.UID, req.SubID)
-		if err != nil {
-			if errors.Is(err, service.ErrSubscriptionExist) {
-				log.Info("subscription already exists", slog.String("user", strconv.Itoa(req.UID)))
+			render.Status(r, http.StatusBadRequest)
+
+			render.JSON(w, r, models.Error("invalid uid"))
 
-				render.Status(r, http.StatusBadRequest)
+			return
+		}
 
-				render.JSON(w, r, models.Error("subscription already exists"))
+		err = subscriber.Subscribe(r.Context(), principal.Email, req.UID)
+		if err != nil {
+			log.Info("failed to subscribe", sl.Err(err))
 
-				return
-			}
+			status, msg := errorStatus(err)
 
-			render.Status(r, http.StatusInternalServerError)
+			render.Status(r, status)
 
-			render.JSON(w, r, models.Error("error while subbing"))
+			render.JSON(w, r, models.Error(msg))
 
 			return
 		}
@@ -84,3 +101,17 @@ func New(log *slog.Logger,
 		})
 	}
 }
+
+// errorStatus maps subscription errors to the HTTP status and message.
+func errorStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, service.ErrAuthorNotFound):
+		return http.StatusNotFound, "author not found"
+	case errors.Is(err, service.ErrUserNotFound):
+		return http.StatusNotFound, "user not found"
+	case errors.Is(err, service.ErrSelfSubscription):
+		return http.StatusBadRequest, "cannot subscribe to yourself"
+	default:
+		return http.StatusInternalServerError, "error while subbing"
+	}
+}
@@ -0,0 +1,60 @@
+package tokens
+
+import (
+	"context"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/maestro-milagro/Post_Service_PB/internal/lib/auth"
+	"github.com/maestro-milagro/Post_Service_PB/internal/lib/sl"
+	"github.com/maestro-milagro/Post_Service_PB/internal/models"
+	"log/slog"
+	"net/http"
+)
+
+type Response struct {
+	Tokens []models.APIToken `json:"tokens"`
+	models.Response
+}
+
+type Lister interface {
+	List(ctx context.Context, email string) ([]models.APIToken, error)
+}
+
+// New lists the caller's API tokens that have not been revoked.
+func New(log *slog.Logger, lister Lister) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.tokens.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		principal, ok := auth.FromContext(r.Context())
+		if !ok {
+			log.Error("request is not authenticated")
+
+			render.Status(r, http.StatusUnauthorized)
+
+			render.JSON(w, r, models.Error("unauthorized"))
+
+			return
+		}
+
+		list, err := lister.List(r.Context(), principal.Email)
+		if err != nil {
+			log.Error("failed to list tokens", sl.Err(err))
+
+			render.Status(r, http.StatusInternalServerError)
+
+			render.JSON(w, r, models.Error("failed to list tokens"))
+
+			return
+		}
+
+		render.JSON(w, r, Response{
+			Tokens:   list,
+			Response: models.OK(),
+		})
+	}
+}
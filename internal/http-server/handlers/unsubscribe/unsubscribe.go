@@ -0,0 +1,81 @@
+package unsubscribe
+
+import (
+	"context"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/maestro-milagro/Post_Service_PB/internal/lib/auth"
+	"github.com/maestro-milagro/Post_Service_PB/internal/lib/sl"
+	"github.com/maestro-milagro/Post_Service_PB/internal/models"
+	"github.com/maestro-milagro/Post_Service_PB/internal/service"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+type Unsubscriber interface {
+	Unsubscribe(ctx context.Context, email string, authorID int) error
+}
+
+// New unsubscribes the caller from the author {id}. Unsubscribing when not
+// subscribed succeeds.
+func New(log *slog.Logger, unsubscriber Unsubscriber) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.unsubscribe.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		principal, ok := auth.FromContext(r.Context())
+		if !ok {
+			log.Error("request is not authenticated")
+
+			render.Status(r, http.StatusUnauthorized)
+
+			render.JSON(w, r, models.Error("unauthorized"))
+
+			return
+		}
+
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil || id < 1 {
+			log.Info("invalid id", slog.String("id", chi.URLParam(r, "id")))
+
+			render.Status(r, http.StatusBadRequest)
+
+			render.JSON(w, r, models.Error("invalid request"))
+
+			return
+		}
+
+		err = unsubscriber.Unsubscribe(r.Context(), principal.Email, id)
+		if err != nil {
+			log.Info("failed to unsubscribe", sl.Err(err))
+
+			status, msg := errorStatus(err)
+
+			render.Status(r, status)
+
+			render.JSON(w, r, models.Error(msg))
+
+			return
+		}
+
+		render.JSON(w, r, models.OK())
+	}
+}
+
+func errorStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, service.ErrUserNotFound):
+		return http.StatusNotFound, "user not found"
+	case errors.Is(err, service.ErrSelfSubscription):
+		return http.StatusBadRequest, "cannot unsubscribe from yourself"
+	default:
+		return http.StatusInternalServerError, "failed to unsubscribe"
+	}
+}
@@ -0,0 +1,252 @@
+package auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/maestro-milagro/Post_Service_PB/internal/lib/auth"
+	"github.com/maestro-milagro/Post_Service_PB/internal/lib/sl"
+	"github.com/maestro-milagro/Post_Service_PB/internal/models"
+	"io"
+	"log/slog"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+)
+
+// maxLegacyBody bounds how much of a request body is read to find the legacy token field.
+const maxLegacyBody = 1 << 20
+
+// maxTokenField bounds the "token" field of a multipart form.
+const maxTokenField = 4 << 10
+
+// New authenticates the request once and puts the auth.Principal into its context.
+// The token is taken from the Authorization: Bearer header or, for older clients,
+// from the "token" field of a JSON body or of a multipart form, where it must
+// precede the file. The body is left intact for the handler.
+func New(log *slog.Logger, authenticator auth.Authenticator) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			log := log.With(
+				slog.String("component", "middleware/auth"),
+				slog.String("request_id", middleware.GetReqID(r.Context())),
+			)
+
+			token, ok := bearer(r.Header.Get("Authorization"))
+			if !ok {
+				log.Info("malformed authorization header")
+
+				unauthorized(w, r, "invalid authorization header")
+
+				return
+			}
+
+			if token == "" {
+				if isMultipart(r) {
+					token = formToken(r)
+				} else {
+					token = legacyToken(r)
+				}
+			}
+			if token == "" {
+				log.Info("token is missing")
+
+				unauthorized(w, r, "missing token")
+
+				return
+			}
+
+			principal, err := authenticator.Authenticate(r.Context(), token)
+			if err != nil {
+				log.Info("failed to authenticate", sl.Err(err))
+
+				unauthorized(w, r, "invalid token")
+
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(auth.NewContext(r.Context(), principal)))
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// RequireScope rejects API tokens whose scopes do not cover scope.
+func RequireScope(log *slog.Logger, scope string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			principal, ok := auth.FromContext(r.Context())
+			if !ok {
+				unauthorized(w, r, "missing token")
+
+				return
+			}
+			if !principal.Allows(scope) {
+				log.Info("token scope is insufficient",
+					slog.String("request_id", middleware.GetReqID(r.Context())),
+					slog.Int64("token_id", principal.TokenID),
+					slog.String("scope", scope),
+				)
+
+				render.Status(r, http.StatusForbidden)
+
+				render.JSON(w, r, models.Error("token lacks the "+scope+" scope"))
+
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// RequireUser admits only users signed in with a JWT, not API tokens, for
+// operations such as managing the tokens themselves.
+func RequireUser(log *slog.Logger) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			principal, ok := auth.FromContext(r.Context())
+			if !ok {
+				unauthorized(w, r, "missing token")
+
+				return
+			}
+			if principal.TokenID != 0 {
+				log.Info("api token used for a user-only route",
+					slog.String("request_id", middleware.GetReqID(r.Context())),
+					slog.Int64("token_id", principal.TokenID),
+				)
+
+				render.Status(r, http.StatusForbidden)
+
+				render.JSON(w, r, models.Error("api tokens cannot be used here"))
+
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// RequireAdmin admits only administrators. API tokens carry no roles and never pass.
+func RequireAdmin(log *slog.Logger) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			principal, ok := auth.FromContext(r.Context())
+			if !ok {
+				unauthorized(w, r, "missing token")
+
+				return
+			}
+			if !principal.IsAdmin() {
+				log.Info("admin route requested by non-admin",
+					slog.String("request_id", middleware.GetReqID(r.Context())),
+					slog.String("user", principal.Email),
+				)
+
+				render.Status(r, http.StatusForbidden)
+
+				render.JSON(w, r, models.Error("admin role required"))
+
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// bearer extracts the token from the Authorization header; an absent header is not an error.
+func bearer(header string) (string, bool) {
+	if header == "" {
+		return "", true
+	}
+	scheme, token, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+func isMultipart(r *http.Request) bool {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return strings.HasPrefix(mediaType, "multipart/")
+}
+
+// legacyToken reads the token field of a JSON body and restores the body for the handler.
+func legacyToken(r *http.Request) string {
+	if r.Body == nil || r.Body == http.NoBody {
+		return ""
+	}
+
+	head, err := io.ReadAll(io.LimitReader(r.Body, maxLegacyBody+1))
+	// Тело возвращаем целиком, даже если прочитали его не до конца
+	r.Body = readCloser{Reader: io.MultiReader(bytes.NewReader(head), r.Body), Closer: r.Body}
+	if err != nil || len(head) > maxLegacyBody {
+		return ""
+	}
+
+	var req struct {
+		Token string `json:"token"`
+	}
+	if err := json.Unmarshal(head, &req); err != nil {
+		return ""
+	}
+	return req.Token
+}
+
+// formToken reads a multipart form up to its "token" field and restores the body for
+// the handler. Only the fields before the file are looked at, the file is not read.
+func formToken(r *http.Request) string {
+	_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || params["boundary"] == "" || r.Body == nil || r.Body == http.NoBody {
+		return ""
+	}
+
+	var head bytes.Buffer
+	mr := multipart.NewReader(io.TeeReader(io.LimitReader(r.Body, maxLegacyBody), &head), params["boundary"])
+	// Всё прочитанное из тела, включая буфер multipart.Reader, отдаём обработчику заново
+	defer func() {
+		r.Body = readCloser{Reader: io.MultiReader(&head, r.Body), Closer: r.Body}
+	}()
+
+	for {
+		part, err := mr.NextPart()
+		if err != nil || part.FileName() != "" {
+			return ""
+		}
+		if part.FormName() != "token" {
+			continue
+		}
+
+		value, err := io.ReadAll(io.LimitReader(part, maxTokenField))
+		if err != nil {
+			return ""
+		}
+		return string(value)
+	}
+}
+
+func unauthorized(w http.ResponseWriter, r *http.Request, msg string) {
+	w.Header().Set("WWW-Authenticate", "Bearer")
+
+	render.Status(r, http.StatusUnauthorized)
+
+	render.JSON(w, r, models.Error(msg))
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,192 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/maestro-milagro/Post_Service_PB/internal/lib/auth"
+	"github.com/maestro-milagro/Post_Service_PB/internal/lib/jwt"
+	"github.com/maestro-milagro/Post_Service_PB/internal/models"
+)
+
+var discard = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// tokens authenticates the tokens it knows.
+type tokens map[string]auth.Principal
+
+func (t tokens) Authenticate(_ context.Context, token string) (auth.Principal, error) {
+	p, ok := t[token]
+	if !ok {
+		return auth.Principal{}, errors.New("unknown token")
+	}
+	return p, nil
+}
+
+var known = tokens{
+	"user":  {Email: "user@example.com"},
+	"admin": {Email: "admin@example.com", Roles: []string{jwt.RoleAdmin}},
+	"read":  {Email: "user@example.com", TokenID: 7, Scopes: []string{models.ScopeRead}},
+}
+
+// echo answers with the authenticated email and the body it received.
+var echo = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	principal, ok := auth.FromContext(r.Context())
+	if !ok {
+		http.Error(w, "no principal", http.StatusTeapot)
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	w.Header().Set("X-Email", principal.Email)
+	w.Write(body)
+})
+
+func form(t *testing.T, fields [][2]string, file string) (string, []byte) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	for _, f := range fields {
+		if f[0] == "file" {
+			part, err := mw.CreateFormFile("file", "a.txt")
+			if err != nil {
+				t.Fatal(err)
+			}
+			part.Write([]byte(file))
+			continue
+		}
+		if err := mw.WriteField(f[0], f[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := mw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return mw.FormDataContentType(), buf.Bytes()
+}
+
+func TestNew(t *testing.T) {
+	handler := New(discard, known)(echo)
+
+	// Файл больше, чем буфер multipart.Reader, чтобы тело возвращалось не целиком из него
+	file := strings.Repeat("x", 64<<10)
+
+	type request struct {
+		auth        string
+		contentType string
+		body        []byte
+	}
+	multipartReq := func(fields ...[2]string) request {
+		contentType, body := form(t, fields, file)
+		return request{contentType: contentType, body: body}
+	}
+
+	tests := []struct {
+		name   string
+		req    request
+		status int
+		email  string
+	}{
+		{"bearer", request{auth: "Bearer user"}, http.StatusOK, "user@example.com"},
+		{"bearer lower case", request{auth: "bearer admin"}, http.StatusOK, "admin@example.com"},
+		{"bad scheme", request{auth: "Basic user"}, http.StatusUnauthorized, ""},
+		{"empty bearer", request{auth: "Bearer "}, http.StatusUnauthorized, ""},
+		{"unknown token", request{auth: "Bearer nope"}, http.StatusUnauthorized, ""},
+		{"no token", request{}, http.StatusUnauthorized, ""},
+		{"json token", request{contentType: "application/json", body: []byte(`{"token":"user","title":"t"}`)},
+			http.StatusOK, "user@example.com"},
+		{"json without token", request{contentType: "application/json", body: []byte(`{"title":"t"}`)},
+			http.StatusUnauthorized, ""},
+		{"json not json", request{contentType: "application/json", body: []byte(`token=user`)},
+			http.StatusUnauthorized, ""},
+		{"form token", multipartReq([2]string{"title", "t"}, [2]string{"token", "user"}, [2]string{"file"}),
+			http.StatusOK, "user@example.com"},
+		{"form token after file", multipartReq([2]string{"file"}, [2]string{"token", "user"}),
+			http.StatusUnauthorized, ""},
+		{"form without token", multipartReq([2]string{"title", "t"}, [2]string{"file"}),
+			http.StatusUnauthorized, ""},
+		{"form unknown token", multipartReq([2]string{"token", "nope"}, [2]string{"file"}),
+			http.StatusUnauthorized, ""},
+		{"form with header", func() request {
+			req := multipartReq([2]string{"file"})
+			req.auth = "Bearer admin"
+			return req
+		}(), http.StatusOK, "admin@example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(tt.req.body))
+			if tt.req.auth != "" {
+				r.Header.Set("Authorization", tt.req.auth)
+			}
+			if tt.req.contentType != "" {
+				r.Header.Set("Content-Type", tt.req.contentType)
+			}
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, r)
+
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if tt.status != http.StatusOK {
+				if w.Header().Get("WWW-Authenticate") != "Bearer" {
+					t.Error("WWW-Authenticate is not set")
+				}
+				return
+			}
+			if got := w.Header().Get("X-Email"); got != tt.email {
+				t.Errorf("principal %q, want %q", got, tt.email)
+			}
+			// Обработчик получает тело целиком, даже если middleware читал из него токен
+			if !bytes.Equal(w.Body.Bytes(), tt.req.body) {
+				t.Errorf("handler got %d bytes of the %d byte body", w.Body.Len(), len(tt.req.body))
+			}
+		})
+	}
+}
+
+func TestRequire(t *testing.T) {
+	tests := []struct {
+		name       string
+		middleware func(next http.Handler) http.Handler
+		token      string
+		status     int
+	}{
+		{"scope without principal", RequireScope(discard, models.ScopeRead), "", http.StatusUnauthorized},
+		{"scope of a user", RequireScope(discard, models.ScopeWrite), "user", http.StatusOK},
+		{"scope granted", RequireScope(discard, models.ScopeRead), "read", http.StatusOK},
+		{"scope missing", RequireScope(discard, models.ScopeWrite), "read", http.StatusForbidden},
+		{"user without principal", RequireUser(discard), "", http.StatusUnauthorized},
+		{"user", RequireUser(discard), "user", http.StatusOK},
+		{"user with api token", RequireUser(discard), "read", http.StatusForbidden},
+		{"admin without principal", RequireAdmin(discard), "", http.StatusUnauthorized},
+		{"admin", RequireAdmin(discard), "admin", http.StatusOK},
+		{"admin as user", RequireAdmin(discard), "user", http.StatusForbidden},
+		{"admin with api token", RequireAdmin(discard), "read", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.token != "" {
+				r = r.WithContext(auth.NewContext(r.Context(), known[tt.token]))
+			}
+			w := httptest.NewRecorder()
+
+			tt.middleware(echo).ServeHTTP(w, r)
+
+			if w.Code != tt.status {
+				t.Errorf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+// Package auth carries the authenticated caller through the request context.
+package auth
+
+import (
+	"context"
+	"errors"
+	"github.com/maestro-milagro/Post_Service_PB/internal/lib/jwt"
+	"slices"
+	"strings"
+)
+
+// Principal is the authenticated caller. UserID is zero when the token does not carry it.
+// TokenID and Scopes are set when the caller uses an API token; nil Scopes allow everything.
+type Principal struct {
+	Email   string
+	UserID  int64
+	Roles   []string
+	TokenID int64
+	Scopes  []string
+}
+
+// Allows reports whether the principal may act within scope.
+func (p Principal) Allows(scope string) bool {
+	return p.Scopes == nil || slices.Contains(p.Scopes, scope)
+}
+
+func (p Principal) HasRole(role string) bool {
+	return slices.Contains(p.Roles, role)
+}
+
+func (p Principal) IsAdmin() bool {
+	return p.HasRole(jwt.RoleAdmin)
+}
+
+type ctxKey struct{}
+
+func NewContext(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, ctxKey{}, p)
+}
+
+// FromContext returns the principal put into ctx by the auth middleware.
+func FromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(ctxKey{}).(Principal)
+	return p, ok
+}
+
+// Authenticator turns a bearer token into the principal it was issued to.
+type Authenticator interface {
+	Authenticate(ctx context.Context, token string) (Principal, error)
+}
+
+// Prefixed sends the tokens starting with Prefix to Tokens and all others to Fallback.
+type Prefixed struct {
+	Prefix   string
+	Tokens   Authenticator
+	Fallback Authenticator
+}
+
+func (p Prefixed) Authenticate(ctx context.Context, token string) (Principal, error) {
+	if strings.HasPrefix(token, p.Prefix) {
+		return p.Tokens.Authenticate(ctx, token)
+	}
+	return p.Fallback.Authenticate(ctx, token)
+}
+
+var ErrRevoked = errors.New("token is revoked")
+
+// Revocations reports whether a valid token has been revoked before it expired.
+type Revocations interface {
+	Revoked(claims jwt.Claims) bool
+}
+
+// JWT authenticates the tokens signed by the auth service.
+type JWT struct {
+	verifier    *jwt.Verifier
+	revocations Revocations
+}
+
+func NewJWT(verifier *jwt.Verifier, revocations Revocations) *JWT {
+	return &JWT{verifier: verifier, revocations: revocations}
+}
+
+func (j *JWT) Authenticate(_ context.Context, token string) (Principal, error) {
+	claims, err := j.verifier.Verify(token)
+	if err != nil {
+		return Principal{}, err
+	}
+	if j.revocations.Revoked(claims) {
+		return Principal{}, ErrRevoked
+	}
+
+	p := Principal{Email: claims.Email, UserID: claims.UserID}
+	if claims.Role != "" {
+		p.Roles = []string{claims.Role}
+	}
+	return p, nil
+}
@@ -0,0 +1,181 @@
+// Package diff computes line diffs with the linear-space variant of Myers'
+// O(ND) algorithm and formats them as unified diff hunks.
+package diff
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	OpEqual  = ' '
+	OpDelete = '-'
+	OpInsert = '+'
+)
+
+var ErrTooManyLines = errors.New("too many lines to diff")
+
+// Limits bounds the work of a diff; zero values leave the bound off.
+type Limits struct {
+	// MaxLines caps the lines of each side, longer texts are refused with ErrTooManyLines.
+	MaxLines int
+	// MaxEdits caps the edit distance searched for in each part of the texts.
+	// A part that differs more is shown as deleted and inserted whole, so the
+	// diff stays correct but is no longer minimal.
+	MaxEdits int
+}
+
+type Line struct {
+	Op   rune   `json:"-"`
+	Kind string `json:"kind"`
+	Text string `json:"text"`
+	// NoNewline marks the last line of a file that does not end with a newline.
+	NoNewline bool `json:"no_newline,omitempty"`
+}
+
+// Hunk is a group of changes with surrounding context. Line numbers are 1-based;
+// for an empty side the start is the line after which the change happens.
+type Hunk struct {
+	FromStart int    `json:"from_start"`
+	FromLines int    `json:"from_lines"`
+	ToStart   int    `json:"to_start"`
+	ToLines   int    `json:"to_lines"`
+	Lines     []Line `json:"lines"`
+}
+
+// IsText reports whether data looks like text that can be diffed line by line.
+func IsText(data []byte) bool {
+	return bytes.IndexByte(data, 0) < 0 && utf8.Valid(data)
+}
+
+// Hunks diffs a against b and groups the changes with context lines of unchanged
+// text around them. It stops with ctx.Err() when ctx is done.
+func Hunks(ctx context.Context, a, b string, contextLines int, limits Limits) ([]Hunk, error) {
+	al, bl := lines(a), lines(b)
+	if limits.MaxLines > 0 && (len(al) > limits.MaxLines || len(bl) > limits.MaxLines) {
+		return nil, ErrTooManyLines
+	}
+
+	ops, err := compare(ctx, al, bl, limits.MaxEdits)
+	if err != nil {
+		return nil, err
+	}
+
+	var hunks []Hunk
+	var cur *Hunk
+	// i, j считают строки a и b; trailing — сколько одинаковых строк подряд в конце текущего ханка
+	i, j, trailing := 0, 0, 0
+
+	for _, op := range ops {
+		if op != OpEqual {
+			if cur == nil {
+				// Начинаем ханк с contextLines строк перед изменением
+				back := min(contextLines, i)
+				cur = &Hunk{FromStart: i - back + 1, ToStart: j - back + 1}
+				for n := back; n > 0; n-- {
+					cur.add(OpEqual, al[i-n])
+				}
+			}
+			trailing = 0
+		}
+
+		switch op {
+		case OpEqual:
+			if cur != nil {
+				if trailing == 2*contextLines {
+					hunks = append(hunks, cur.trim(trailing-contextLines))
+					cur = nil
+				} else {
+					cur.add(OpEqual, al[i])
+					trailing++
+				}
+			}
+			i++
+			j++
+		case OpDelete:
+			cur.add(OpDelete, al[i])
+			i++
+		case OpInsert:
+			cur.add(OpInsert, bl[j])
+			j++
+		}
+	}
+
+	if cur != nil {
+		hunks = append(hunks, cur.trim(max(trailing-contextLines, 0)))
+	}
+
+	return hunks, nil
+}
+
+// Unified formats hunks as a unified diff between files named from and to.
+func Unified(from, to string, hunks []Hunk) string {
+	if len(hunks) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", from, to)
+
+	for _, h := range hunks {
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n", span(h.FromStart, h.FromLines), span(h.ToStart, h.ToLines))
+		for _, l := range h.Lines {
+			sb.WriteRune(l.Op)
+			sb.WriteString(l.Text)
+			sb.WriteByte('\n')
+			if l.NoNewline {
+				sb.WriteString("\\ No newline at end of file\n")
+			}
+		}
+	}
+
+	return sb.String()
+}
+
+func span(start, n int) string {
+	if n == 1 {
+		return fmt.Sprint(start)
+	}
+	if n == 0 {
+		start--
+	}
+	return fmt.Sprintf("%d,%d", start, n)
+}
+
+func (h *Hunk) add(op rune, line string) {
+	l := Line{Op: op, Text: strings.TrimSuffix(line, "\n"), NoNewline: !strings.HasSuffix(line, "\n")}
+	switch op {
+	case OpEqual:
+		l.Kind = "equal"
+		h.FromLines++
+		h.ToLines++
+	case OpDelete:
+		l.Kind = "delete"
+		h.FromLines++
+	case OpInsert:
+		l.Kind = "insert"
+		h.ToLines++
+	}
+	h.Lines = append(h.Lines, l)
+}
+
+// trim drops the last n lines, which are all context.
+func (h *Hunk) trim(n int) Hunk {
+	h.Lines = h.Lines[:len(h.Lines)-n]
+	h.FromLines -= n
+	h.ToLines -= n
+	return *h
+}
+
+// lines splits s after each newline; a missing final newline leaves the last line without one.
+func lines(s string) []string {
+	l := strings.SplitAfter(s, "\n")
+	if l[len(l)-1] == "" {
+		l = l[:len(l)-1]
+	}
+	return l
+}
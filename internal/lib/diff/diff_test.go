@@ -0,0 +1,224 @@
+package diff
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUnified(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{
+			name: "equal",
+			a:    "a\nb\n",
+			b:    "a\nb\n",
+			want: "",
+		},
+		{
+			name: "change in the middle",
+			a:    "1\n2\n3\n4\n5\n6\n7\n8\n9\n",
+			b:    "1\n2\n3\n4\nfive\n6\n7\n8\n9\n",
+			want: "--- a\n+++ b\n@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n",
+		},
+		{
+			name: "insert into empty",
+			a:    "",
+			b:    "x\n",
+			want: "--- a\n+++ b\n@@ -0,0 +1 @@\n+x\n",
+		},
+		{
+			name: "delete everything",
+			a:    "x\ny\n",
+			b:    "",
+			want: "--- a\n+++ b\n@@ -1,2 +0,0 @@\n-x\n-y\n",
+		},
+		{
+			name: "missing final newline",
+			a:    "x\ny",
+			b:    "x\ny\n",
+			want: "--- a\n+++ b\n@@ -1,2 +1,2 @@\n x\n-y\n\\ No newline at end of file\n+y\n",
+		},
+		{
+			name: "distant changes make two hunks",
+			a:    "a\n1\n2\n3\n4\n5\n6\n7\nb\n",
+			b:    "A\n1\n2\n3\n4\n5\n6\n7\nB\n",
+			want: "--- a\n+++ b\n@@ -1,4 +1,4 @@\n-a\n+A\n 1\n 2\n 3\n@@ -6,4 +6,4 @@\n 5\n 6\n 7\n-b\n+B\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hunks, err := Hunks(context.Background(), tt.a, tt.b, 3, Limits{})
+			if err != nil {
+				t.Fatalf("Hunks: %v", err)
+			}
+			if got := Unified("a", "b", hunks); got != tt.want {
+				t.Errorf("Unified:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHunksReconstruct(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 500; i++ {
+		a, b := randomText(rnd, 30), randomText(rnd, 30)
+		limits := Limits{}
+		if i%2 == 1 {
+			// С маленьким бюджетом дифф перестаёт быть минимальным, но должен оставаться верным
+			limits.MaxEdits = 2
+		}
+
+		hunks, err := Hunks(context.Background(), a, b, rnd.Intn(4), limits)
+		if err != nil {
+			t.Fatalf("Hunks: %v", err)
+		}
+		if got := apply(a, hunks); got != b {
+			t.Fatalf("applying hunks to %q gives %q, want %q", a, got, b)
+		}
+	}
+}
+
+func TestHunksMinimal(t *testing.T) {
+	rnd := rand.New(rand.NewSource(2))
+
+	for i := 0; i < 200; i++ {
+		a, b := randomText(rnd, 20), randomText(rnd, 20)
+
+		hunks, err := Hunks(context.Background(), a, b, 0, Limits{})
+		if err != nil {
+			t.Fatalf("Hunks: %v", err)
+		}
+
+		al, bl := lines(a), lines(b)
+		edits := 0
+		for _, h := range hunks {
+			edits += h.FromLines + h.ToLines
+		}
+		if want := len(al) + len(bl) - 2*lcs(al, bl); edits != want {
+			t.Fatalf("diff of %q and %q has %d edits, want %d", a, b, edits, want)
+		}
+	}
+}
+
+func TestHunksEditBudget(t *testing.T) {
+	var a, b strings.Builder
+	for i := 0; i < 20000; i++ {
+		fmt.Fprintf(&a, "a%d\n", i)
+		fmt.Fprintf(&b, "b%d\n", i)
+	}
+
+	start := time.Now()
+	hunks, err := Hunks(context.Background(), a.String(), b.String(), 3, Limits{MaxEdits: 1000})
+	if err != nil {
+		t.Fatalf("Hunks: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("diff took %s", elapsed)
+	}
+	if got := apply(a.String(), hunks); got != b.String() {
+		t.Error("hunks do not reconstruct the target")
+	}
+}
+
+func TestHunksLimits(t *testing.T) {
+	_, err := Hunks(context.Background(), "1\n2\n3\n", "1\n", 3, Limits{MaxLines: 2})
+	if !errors.Is(err, ErrTooManyLines) {
+		t.Errorf("MaxLines: got %v, want ErrTooManyLines", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = Hunks(ctx, "a\nb\n", "c\nd\n", 3, Limits{})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled context: got %v, want context.Canceled", err)
+	}
+}
+
+func TestIsText(t *testing.T) {
+	tests := []struct {
+		data []byte
+		want bool
+	}{
+		{[]byte("hello\n"), true},
+		{[]byte("привет"), true},
+		{[]byte{'a', 0, 'b'}, false},
+		{[]byte{0xff, 0xfe}, false},
+	}
+	for _, tt := range tests {
+		if got := IsText(tt.data); got != tt.want {
+			t.Errorf("IsText(%q) = %v, want %v", tt.data, got, tt.want)
+		}
+	}
+}
+
+// randomText builds up to n lines from a small alphabet, so the texts share lines.
+func randomText(rnd *rand.Rand, n int) string {
+	var sb strings.Builder
+	for i := rnd.Intn(n + 1); i > 0; i-- {
+		sb.WriteByte(byte('a' + rnd.Intn(4)))
+		sb.WriteByte('\n')
+	}
+	s := sb.String()
+	if s != "" && rnd.Intn(4) == 0 {
+		s = strings.TrimSuffix(s, "\n")
+	}
+	return s
+}
+
+// apply rebuilds the target text from the source and the hunks.
+func apply(a string, hunks []Hunk) string {
+	al := lines(a)
+	var sb strings.Builder
+	pos := 0
+	for _, h := range hunks {
+		start := h.FromStart - 1
+		for ; pos < start; pos++ {
+			sb.WriteString(al[pos])
+		}
+		for _, l := range h.Lines {
+			switch l.Op {
+			case OpEqual:
+				sb.WriteString(al[pos])
+				pos++
+			case OpDelete:
+				pos++
+			case OpInsert:
+				sb.WriteString(l.Text)
+				if !l.NoNewline {
+					sb.WriteByte('\n')
+				}
+			}
+		}
+	}
+	for ; pos < len(al); pos++ {
+		sb.WriteString(al[pos])
+	}
+	return sb.String()
+}
+
+func lcs(a, b []string) int {
+	dp := make([][]int, len(a)+1)
+	for i := range dp {
+		dp[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				dp[i][j] = dp[i+1][j+1] + 1
+			} else {
+				dp[i][j] = max(dp[i+1][j], dp[i][j+1])
+			}
+		}
+	}
+	return dp[0][0]
+}
@@ -0,0 +1,159 @@
+package diff
+
+import "context"
+
+// compare returns the edit script turning a into b, deletions before insertions
+// within each change. maxEdits bounds the search, see Limits.
+func compare(ctx context.Context, a, b []string, maxEdits int) ([]rune, error) {
+	d := &differ{
+		ctx:      ctx,
+		a:        a,
+		b:        b,
+		deleted:  make([]bool, len(a)),
+		added:    make([]bool, len(b)),
+		maxEdits: maxEdits,
+	}
+	n := len(a) + len(b) + 2
+	d.vf = make([]int, 2*n+1)
+	d.vb = make([]int, 2*n+1)
+
+	if err := d.seq(0, len(a), 0, len(b)); err != nil {
+		return nil, err
+	}
+
+	ops := make([]rune, 0, len(a)+len(b))
+	for i, j := 0, 0; i < len(a) || j < len(b); {
+		switch {
+		case i < len(a) && d.deleted[i]:
+			ops = append(ops, OpDelete)
+			i++
+		case j < len(b) && d.added[j]:
+			ops = append(ops, OpInsert)
+			j++
+		default:
+			ops = append(ops, OpEqual)
+			i++
+			j++
+		}
+	}
+	return ops, nil
+}
+
+type differ struct {
+	ctx            context.Context
+	a, b           []string
+	deleted, added []bool
+	maxEdits       int
+	// vf и vb — самые дальние x на диагоналях для прямого и обратного поиска, со сдвигом на len(vf)/2
+	vf, vb []int
+}
+
+// seq marks the lines of a[aLo:aHi] and b[bLo:bHi] that are not in their longest common subsequence.
+func (d *differ) seq(aLo, aHi, bLo, bHi int) error {
+	for aLo < aHi && bLo < bHi && d.a[aLo] == d.b[bLo] {
+		aLo++
+		bLo++
+	}
+	for aLo < aHi && bLo < bHi && d.a[aHi-1] == d.b[bHi-1] {
+		aHi--
+		bHi--
+	}
+
+	switch {
+	case aLo == aHi:
+		for j := bLo; j < bHi; j++ {
+			d.added[j] = true
+		}
+	case bLo == bHi:
+		for i := aLo; i < aHi; i++ {
+			d.deleted[i] = true
+		}
+	default:
+		x, y, ok, err := d.middle(aLo, aHi, bLo, bHi)
+		if err != nil {
+			return err
+		}
+		// Без точки разбиения или без прогресса (после отсечения общих краёв такого быть
+		// не должно) заменяем часть целиком: это верный, хоть и не минимальный дифф
+		if !ok || (x == aLo && y == bLo) || (x == aHi && y == bHi) {
+			for i := aLo; i < aHi; i++ {
+				d.deleted[i] = true
+			}
+			for j := bLo; j < bHi; j++ {
+				d.added[j] = true
+			}
+			return nil
+		}
+		if err := d.seq(aLo, x, bLo, y); err != nil {
+			return err
+		}
+		return d.seq(x, aHi, y, bHi)
+	}
+
+	return nil
+}
+
+// middle finds a point on an optimal edit path through the middle of the edit graph
+// by running the forward and the backward search until they overlap. It gives up,
+// returning false, once the searches pass maxEdits without meeting.
+func (d *differ) middle(aLo, aHi, bLo, bHi int) (int, int, bool, error) {
+	n, m := aHi-aLo, bHi-bLo
+	delta := n - m
+	odd := delta%2 != 0
+	off := len(d.vf) / 2
+
+	d.vf[off+1] = 0
+	d.vb[off+1] = 0
+
+	limit := (n + m + 1) / 2
+	if d.maxEdits > 0 {
+		limit = min(limit, d.maxEdits)
+	}
+
+	for D := 0; D <= limit; D++ {
+		if err := d.ctx.Err(); err != nil {
+			return 0, 0, false, err
+		}
+
+		for k := -D; k <= D; k += 2 {
+			var x int
+			if k == -D || (k != D && d.vf[off+k-1] < d.vf[off+k+1]) {
+				x = d.vf[off+k+1]
+			} else {
+				x = d.vf[off+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && d.a[aLo+x] == d.b[bLo+y] {
+				x++
+				y++
+			}
+			d.vf[off+k] = x
+
+			// Диагональ k прямого поиска — это диагональ delta-k обратного
+			if odd && delta-k >= -(D-1) && delta-k <= D-1 && x+d.vb[off+delta-k] >= n {
+				return aLo + x, bLo + y, true, nil
+			}
+		}
+
+		for k := -D; k <= D; k += 2 {
+			var x int
+			if k == -D || (k != D && d.vb[off+k-1] < d.vb[off+k+1]) {
+				x = d.vb[off+k+1]
+			} else {
+				x = d.vb[off+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && d.a[aHi-1-x] == d.b[bHi-1-y] {
+				x++
+				y++
+			}
+			d.vb[off+k] = x
+
+			if !odd && delta-k >= -D && delta-k <= D && x+d.vf[off+delta-k] >= n {
+				return aHi - x, bHi - y, true, nil
+			}
+		}
+	}
+
+	return 0, 0, false, nil
+}
@@ -0,0 +1,69 @@
+package highlight
+
+import (
+	"container/list"
+	"sync"
+)
+
+// cache is an LRU cache of rendered pages bounded by their total size.
+type cache struct {
+	mu      sync.Mutex
+	maxSize int64
+	size    int64
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type entry struct {
+	key  string
+	page []byte
+}
+
+func newCache(maxSize int64) *cache {
+	return &cache{
+		maxSize: maxSize,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+func (c *cache) get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+
+	return el.Value.(*entry).page, true
+}
+
+func (c *cache) add(key string, page []byte) {
+	// Страница больше всего кэша вытеснила бы всё остальное
+	if int64(len(page)) > c.maxSize {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		c.order.MoveToFront(el)
+
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&entry{key: key, page: page})
+	c.size += int64(len(page))
+
+	for c.size > c.maxSize {
+		oldest := c.order.Back()
+		e := oldest.Value.(*entry)
+
+		c.order.Remove(oldest)
+		delete(c.entries, e.key)
+		c.size -= int64(len(e.page))
+	}
+}
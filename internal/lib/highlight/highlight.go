@@ -0,0 +1,97 @@
+// Package highlight renders text posts as syntax-highlighted HTML pages.
+package highlight
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+)
+
+var ErrUnknownSyntax = errors.New("unknown syntax")
+
+// LinePrefix prefixes the line anchors, #L12 links to the twelfth line.
+const LinePrefix = "L"
+
+// Renderer highlights posts and keeps the rendered pages in an LRU cache.
+type Renderer struct {
+	style     *chroma.Style
+	formatter *html.Formatter
+	cache     *cache
+}
+
+// New returns a renderer using the named chroma style whose cache holds up to cacheSize bytes of HTML.
+func New(style string, cacheSize int64) *Renderer {
+	return &Renderer{
+		style: styles.Get(style),
+		formatter: html.New(
+			html.Standalone(true),
+			html.WithLineNumbers(true),
+			html.WithLinkableLineNumbers(true, LinePrefix),
+			html.TabWidth(4),
+		),
+		cache: newCache(cacheSize),
+	}
+}
+
+// Syntax returns the canonical name of the named language, so it can be stored with the post.
+func Syntax(name string) (string, error) {
+	lexer := lexers.Get(name)
+	if lexer == nil {
+		return "", fmt.Errorf("%w: %q", ErrUnknownSyntax, name)
+	}
+	return lexer.Config().Name, nil
+}
+
+// Render highlights text, detecting the language from syntax, then fileName, then the content itself.
+// Pages are cached by checksum, which must identify text; an empty checksum disables caching.
+func (r *Renderer) Render(checksum string, fileName string, syntax string, text string) ([]byte, error) {
+	const op = "highlight.Render"
+
+	lexer := detect(fileName, syntax, text)
+
+	// Одно и то же содержимое может быть показано с разной подсветкой
+	key := checksum + "/" + lexer.Config().Name
+	if checksum != "" {
+		if page, ok := r.cache.get(key); ok {
+			return page, nil
+		}
+	}
+
+	tokens, err := chroma.Coalesce(lexer).Tokenise(nil, text)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var buf bytes.Buffer
+	if err := r.formatter.Format(&buf, r.style, tokens); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	page := buf.Bytes()
+	if checksum != "" {
+		r.cache.add(key, page)
+	}
+
+	return page, nil
+}
+
+func detect(fileName string, syntax string, text string) chroma.Lexer {
+	var lexer chroma.Lexer
+	if syntax != "" {
+		lexer = lexers.Get(syntax)
+	}
+	if lexer == nil && fileName != "" {
+		lexer = lexers.Match(fileName)
+	}
+	if lexer == nil {
+		lexer = lexers.Analyse(text)
+	}
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+	return lexer
+}
@@ -0,0 +1,84 @@
+package httprange
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var ErrUnsatisfiable = errors.New("range not satisfiable")
+
+type Range struct {
+	Start  int64
+	Length int64
+}
+
+// ContentRange formats the Content-Range header value for r within an object of the given size.
+func (r Range) ContentRange(size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", r.Start, r.Start+r.Length-1, size)
+}
+
+// Parse interprets a Range header against an object of the given size.
+// Only a single byte range is supported: multi-range and malformed headers
+// yield ok == false, meaning the whole object should be served, as RFC 9110 allows.
+func Parse(header string, size int64) (rng Range, ok bool, err error) {
+	if header == "" {
+		return Range{}, false, nil
+	}
+
+	spec, found := strings.CutPrefix(header, "bytes=")
+	if !found || strings.Contains(spec, ",") {
+		return Range{}, false, nil
+	}
+
+	startStr, endStr, found := strings.Cut(strings.TrimSpace(spec), "-")
+	if !found {
+		return Range{}, false, nil
+	}
+
+	if startStr == "" {
+		// bytes=-N: последние N байт
+		n, err := parseDigits(endStr)
+		if err != nil {
+			return Range{}, false, nil
+		}
+		if n == 0 || size == 0 {
+			return Range{}, false, ErrUnsatisfiable
+		}
+		if n > size {
+			n = size
+		}
+		return Range{Start: size - n, Length: n}, true, nil
+	}
+
+	start, err := parseDigits(startStr)
+	if err != nil {
+		return Range{}, false, nil
+	}
+	if start >= size {
+		return Range{}, false, ErrUnsatisfiable
+	}
+
+	end := size - 1
+	if endStr != "" {
+		end, err = parseDigits(endStr)
+		if err != nil || end < start {
+			return Range{}, false, nil
+		}
+		if end >= size {
+			end = size - 1
+		}
+	}
+
+	return Range{Start: start, Length: end - start + 1}, true, nil
+}
+
+// parseDigits parses a position of the header, which RFC 9110 allows to be digits only.
+func parseDigits(s string) (int64, error) {
+	// ParseInt принимает и знак, а "+5" позицией не является
+	if s == "" || strings.TrimLeft(s, "0123456789") != "" {
+		return 0, strconv.ErrSyntax
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
@@ -0,0 +1,57 @@
+package httprange
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		header  string
+		size    int64
+		want    Range
+		ok      bool
+		wantErr error
+	}{
+		{header: "", size: 100},
+		{header: "bytes=0-9", size: 100, want: Range{0, 10}, ok: true},
+		{header: "bytes=10-", size: 100, want: Range{10, 90}, ok: true},
+		{header: "bytes=99-99", size: 100, want: Range{99, 1}, ok: true},
+		// Конец за пределами объекта обрезается по размеру
+		{header: "bytes=90-200", size: 100, want: Range{90, 10}, ok: true},
+		{header: "bytes=-10", size: 100, want: Range{90, 10}, ok: true},
+		{header: "bytes=-200", size: 100, want: Range{0, 100}, ok: true},
+		{header: "bytes=100-", size: 100, wantErr: ErrUnsatisfiable},
+		{header: "bytes=0-", size: 0, wantErr: ErrUnsatisfiable},
+		{header: "bytes=-0", size: 100, wantErr: ErrUnsatisfiable},
+		{header: "bytes=-5", size: 0, wantErr: ErrUnsatisfiable},
+		// Неподдерживаемые и некорректные заголовки означают весь объект
+		{header: "bytes=0-1,5-6", size: 100},
+		{header: "items=0-1", size: 100},
+		{header: "bytes=5", size: 100},
+		{header: "bytes=9-5", size: 100},
+		{header: "bytes=a-5", size: 100},
+		{header: "bytes=+1-5", size: 100},
+		{header: "bytes=-+5", size: 100},
+		{header: "bytes=1--5", size: 100},
+		{header: "bytes=-", size: 100},
+		{header: "bytes=99999999999999999999-", size: 100},
+	}
+
+	for _, tt := range tests {
+		got, ok, err := Parse(tt.header, tt.size)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("Parse(%q, %d): error %v, want %v", tt.header, tt.size, err, tt.wantErr)
+			continue
+		}
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("Parse(%q, %d) = %+v, %v, want %+v, %v", tt.header, tt.size, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestContentRange(t *testing.T) {
+	if got, want := (Range{Start: 10, Length: 5}).ContentRange(100), "bytes 10-14/100"; got != want {
+		t.Errorf("ContentRange = %q, want %q", got, want)
+	}
+}
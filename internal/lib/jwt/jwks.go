@@ -0,0 +1,200 @@
+package jwt
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+)
+
+// KeySet is the set of public keys tokens may be signed with, keyed by kid.
+// Several keys are listed while the issuer rotates from one to another.
+type KeySet struct {
+	keys map[string]publicKey
+}
+
+type publicKey struct {
+	alg string
+	key any
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// LoadKeySet reads an RFC 7517 JWK Set from path. Keys not meant for
+// signatures (use other than "sig") are skipped.
+func LoadKeySet(path string) (*KeySet, error) {
+	const op = "lib.jwt.LoadKeySet"
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	ks := &KeySet{keys: make(map[string]publicKey, len(set.Keys))}
+	for i, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		if _, dup := ks.keys[k.Kid]; dup {
+			return nil, fmt.Errorf("%s: duplicate kid %q", op, k.Kid)
+		}
+
+		pk, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("%s: key %d (kid %q): %w", op, i, k.Kid, err)
+		}
+		ks.keys[k.Kid] = pk
+	}
+	if len(ks.keys) == 0 {
+		return nil, fmt.Errorf("%s: no signing keys in %s", op, path)
+	}
+
+	return ks, nil
+}
+
+// Key returns the key with the given kid for verifying an alg signature.
+// A token without kid is accepted only when the set holds a single key.
+func (ks *KeySet) Key(kid string, alg string) (any, error) {
+	pk, ok := ks.keys[kid]
+	if !ok && kid == "" && len(ks.keys) == 1 {
+		for _, only := range ks.keys {
+			pk, ok = only, true
+		}
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: kid %q", ErrUnknownKey, kid)
+	}
+	// Ключ одного типа не должен проверять подписи другого алгоритма
+	if pk.alg != alg {
+		return nil, fmt.Errorf("%w: kid %q is a %s key, token is signed with %s", ErrUnknownKey, kid, pk.alg, alg)
+	}
+	return pk.key, nil
+}
+
+func (k jwk) publicKey() (publicKey, error) {
+	var pk publicKey
+	var err error
+
+	switch k.Kty {
+	case "RSA":
+		pk.alg = "RS256"
+		pk.key, err = k.rsa()
+	case "EC":
+		pk.alg = "ES256"
+		pk.key, err = k.ecdsa()
+	case "OKP":
+		pk.alg = "EdDSA"
+		pk.key, err = k.ed25519()
+	default:
+		return publicKey{}, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+	if err != nil {
+		return publicKey{}, err
+	}
+	if k.Alg != "" && k.Alg != pk.alg {
+		return publicKey{}, fmt.Errorf("unsupported algorithm %q for %s key", k.Alg, k.Kty)
+	}
+
+	return pk, nil
+}
+
+func (k jwk) rsa() (*rsa.PublicKey, error) {
+	n, err := decodeInt(k.N)
+	if err != nil {
+		return nil, fmt.Errorf("n: %w", err)
+	}
+	e, err := decodeInt(k.E)
+	if err != nil {
+		return nil, fmt.Errorf("e: %w", err)
+	}
+	if n.BitLen() < 2048 {
+		return nil, fmt.Errorf("RSA key of %d bits is too short", n.BitLen())
+	}
+	if !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+		return nil, errors.New("invalid RSA exponent")
+	}
+	return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+}
+
+func (k jwk) ecdsa() (*ecdsa.PublicKey, error) {
+	if k.Crv != "P-256" {
+		return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+	}
+	x, err := decodeFixed(k.X, 32)
+	if err != nil {
+		return nil, fmt.Errorf("x: %w", err)
+	}
+	y, err := decodeFixed(k.Y, 32)
+	if err != nil {
+		return nil, fmt.Errorf("y: %w", err)
+	}
+
+	// ecdh проверяет, что точка лежит на кривой
+	point := append(append([]byte{4}, x...), y...)
+	if _, err := ecdh.P256().NewPublicKey(point); err != nil {
+		return nil, err
+	}
+
+	return &ecdsa.PublicKey{
+		Curve: elliptic.P256(),
+		X:     new(big.Int).SetBytes(x),
+		Y:     new(big.Int).SetBytes(y),
+	}, nil
+}
+
+func (k jwk) ed25519() (ed25519.PublicKey, error) {
+	if k.Crv != "Ed25519" {
+		return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+	}
+	x, err := decodeFixed(k.X, ed25519.PublicKeySize)
+	if err != nil {
+		return nil, fmt.Errorf("x: %w", err)
+	}
+	return ed25519.PublicKey(x), nil
+}
+
+func decodeInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, errors.New("empty value")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+func decodeFixed(s string, size int) ([]byte, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) != size {
+		return nil, fmt.Errorf("expected %d bytes, got %d", size, len(b))
+	}
+	return b, nil
+}
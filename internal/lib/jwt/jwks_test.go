@@ -0,0 +1,196 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func rsaJWK(kid string, pub *rsa.PublicKey) map[string]string {
+	return map[string]string{
+		"kty": "RSA",
+		"kid": kid,
+		"n":   b64(pub.N.Bytes()),
+		"e":   b64(big.NewInt(int64(pub.E)).Bytes()),
+	}
+}
+
+func ecJWK(kid string, pub *ecdsa.PublicKey) map[string]string {
+	return map[string]string{
+		"kty": "EC",
+		"kid": kid,
+		"crv": "P-256",
+		"x":   b64(pub.X.FillBytes(make([]byte, 32))),
+		"y":   b64(pub.Y.FillBytes(make([]byte, 32))),
+	}
+}
+
+func okpJWK(kid string, pub ed25519.PublicKey) map[string]string {
+	return map[string]string{
+		"kty": "OKP",
+		"kid": kid,
+		"crv": "Ed25519",
+		"x":   b64(pub),
+	}
+}
+
+// with returns a copy of the key with the given fields replaced.
+func with(key map[string]string, fields ...string) map[string]string {
+	out := make(map[string]string, len(key))
+	for k, v := range key {
+		out[k] = v
+	}
+	for i := 0; i+1 < len(fields); i += 2 {
+		out[fields[i]] = fields[i+1]
+	}
+	return out
+}
+
+func writeJWKS(t *testing.T, keys ...map[string]string) string {
+	t.Helper()
+
+	data, err := json.Marshal(map[string]any{"keys": keys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// testKeys are the signing keys of the tests, generated once.
+type testKeys struct {
+	rsa *rsa.PrivateKey
+	ec  *ecdsa.PrivateKey
+	ed  ed25519.PrivateKey
+}
+
+func newTestKeys(t *testing.T) testKeys {
+	t.Helper()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testKeys{rsa: rsaKey, ec: ecKey, ed: edKey}
+}
+
+func (k testKeys) jwks() []map[string]string {
+	return []map[string]string{
+		rsaJWK("rsa", &k.rsa.PublicKey),
+		ecJWK("ec", &k.ec.PublicKey),
+		okpJWK("ed", k.ed.Public().(ed25519.PublicKey)),
+	}
+}
+
+func TestLoadKeySet(t *testing.T) {
+	keys := newTestKeys(t)
+	rsaKey, ecKey, edKey := keys.jwks()[0], keys.jwks()[1], keys.jwks()[2]
+
+	short, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		keys []map[string]string
+		ok   bool
+	}{
+		{"rsa", []map[string]string{rsaKey}, true},
+		{"rsa under 2048 bits", []map[string]string{rsaJWK("short", &short.PublicKey)}, false},
+		{"rsa with bad exponent", []map[string]string{with(rsaKey, "e", b64([]byte{1}))}, false},
+		{"p-256", []map[string]string{ecKey}, true},
+		{"p-384", []map[string]string{with(ecKey, "crv", "P-384")}, false},
+		{"point off the curve", []map[string]string{with(ecKey, "y", ecKey["x"])}, false},
+		{"ed25519", []map[string]string{edKey}, true},
+		{"ed448", []map[string]string{with(edKey, "crv", "Ed448")}, false},
+		{"short ed25519", []map[string]string{with(edKey, "x", b64([]byte{1, 2, 3}))}, false},
+		{"unsupported kty", []map[string]string{{"kty": "oct", "kid": "hmac", "k": b64([]byte("secret"))}}, false},
+		{"alg mismatch", []map[string]string{with(rsaKey, "alg", "PS256")}, false},
+		{"matching alg", []map[string]string{with(ecKey, "alg", "ES256")}, true},
+		{"duplicate kid", []map[string]string{rsaKey, with(ecKey, "kid", "rsa")}, false},
+		{"encryption keys skipped", []map[string]string{with(rsaKey, "use", "enc"), edKey}, true},
+		{"no signing keys", []map[string]string{with(rsaKey, "use", "enc")}, false},
+		{"all supported", keys.jwks(), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadKeySet(writeJWKS(t, tt.keys...))
+			if (err == nil) != tt.ok {
+				t.Errorf("got %v, want ok=%v", err, tt.ok)
+			}
+		})
+	}
+
+	if _, err := LoadKeySet(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("missing file loaded")
+	}
+}
+
+func TestKeySetKey(t *testing.T) {
+	keys := newTestKeys(t)
+
+	set, err := LoadKeySet(writeJWKS(t, keys.jwks()...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	single, err := LoadKeySet(writeJWKS(t, keys.jwks()[1]))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		set  *KeySet
+		kid  string
+		alg  string
+		ok   bool
+	}{
+		{"rsa", set, "rsa", "RS256", true},
+		{"ec", set, "ec", "ES256", true},
+		{"ed", set, "ed", "EdDSA", true},
+		{"unknown kid", set, "other", "RS256", false},
+		{"no kid among several keys", set, "", "RS256", false},
+		{"no kid with a single key", single, "", "ES256", true},
+		// Алгоритм берётся из ключа, а не из заголовка токена
+		{"rsa key for es256", set, "rsa", "ES256", false},
+		{"ec key for hs256", set, "ec", "HS256", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.set.Key(tt.kid, tt.alg)
+			if tt.ok != (err == nil) {
+				t.Fatalf("got %v, want ok=%v", err, tt.ok)
+			}
+			if err != nil && !errors.Is(err, ErrUnknownKey) {
+				t.Errorf("got %v, want ErrUnknownKey", err)
+			}
+		})
+	}
+}
@@ -1,40 +1,179 @@
 package jwt
 
 import (
+	"errors"
 	"fmt"
 	"github.com/golang-jwt/jwt/v5"
-	"github.com/maestro-milagro/Post_Service_PB/internal/lib/sl"
-	"log/slog"
+	"time"
 )
 
-func VerifyToken(log *slog.Logger, secret string, token string) (string, error) {
-	const op = "lib.jwt.VerifyToken"
+const RoleAdmin = "admin"
 
-	tok := jwt.New(jwt.SigningMethodHS256)
+// Errors returned by Verifier.Verify. They wrap the underlying library error,
+// so callers may log the details but should only branch on these.
+var (
+	ErrMalformed        = errors.New("token is malformed")
+	ErrInvalidSignature = errors.New("token signature is invalid")
+	ErrUnknownKey       = errors.New("token is signed with an unknown key")
+	ErrExpired          = errors.New("token is expired")
+	ErrNotYetValid      = errors.New("token is not valid yet")
+	ErrInvalidIssuer    = errors.New("token has invalid issuer")
+	ErrInvalidAudience  = errors.New("token has invalid audience")
+	ErrInvalidClaim     = errors.New("token has invalid claim")
+)
+
+// Claims are the verified claims of a token. ID (jti) and IssuedAt are empty
+// when the token does not carry them; ExpiresAt is always set.
+type Claims struct {
+	Email     string
+	UserID    int64
+	Role      string
+	ID        string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
+
+func (c Claims) IsAdmin() bool {
+	return c.Role == RoleAdmin
+}
+
+// Config describes the accepted tokens. Secret enables HS256 tokens signed with
+// the shared secret, JWKSFile the asymmetric ones (RS256, ES256, EdDSA) signed
+// with the keys of a local JWKS file; at least one of them has to be set.
+// Issuer and Audience are checked when set. Leeway absorbs clock skew.
+type Config struct {
+	Secret   string
+	JWKSFile string
+	Issuer   string
+	Audience string
+	Leeway   time.Duration
+}
+
+type Verifier struct {
+	secret []byte
+	keys   *KeySet
+	parser *jwt.Parser
+}
 
-	claims := tok.Claims.(jwt.MapClaims)
+func NewVerifier(cfg Config) (*Verifier, error) {
+	const op = "lib.jwt.NewVerifier"
 
-	keyFunc := func(t *jwt.Token) (interface{}, error) {
-		// Проверяем, что используется ожидаемый метод подписи
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("Unexpected signature method: %v", t.Header["alg"])
+	v := &Verifier{}
+
+	var methods []string
+	if cfg.Secret != "" {
+		v.secret = []byte(cfg.Secret)
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+	if cfg.JWKSFile != "" {
+		keys, err := LoadKeySet(cfg.JWKSFile)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
 		}
-		// Возвращаем секретный ключ для jwt токена, в формате []byte, совпадающий с ключом, использованным для подписи ранее
-		return []byte(secret), nil
+		v.keys = keys
+		methods = append(methods, jwt.SigningMethodRS256.Alg(), jwt.SigningMethodES256.Alg(), jwt.SigningMethodEdDSA.Alg())
+	}
+	if len(methods) == 0 {
+		return nil, fmt.Errorf("%s: neither secret nor JWKS file is configured", op)
+	}
+
+	opts := []jwt.ParserOption{
+		// Список алгоритмов фиксирован, иначе токен сам выбирал бы, как его проверять
+		jwt.WithValidMethods(methods),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(cfg.Leeway),
 	}
+	if cfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		opts = append(opts, jwt.WithAudience(cfg.Audience))
+	}
+	v.parser = jwt.NewParser(opts...)
+
+	return v, nil
+}
 
-	parsedToken, err := jwt.ParseWithClaims(token, claims, keyFunc)
-	if err != nil {
-		log.Error("failed to parse token", sl.Err(err))
+// Verify checks the signature and the registered claims of token and returns its claims.
+func (v *Verifier) Verify(token string) (Claims, error) {
+	const op = "lib.jwt.Verify"
 
-		return "", fmt.Errorf("%s: %w", op, err)
+	claims := jwt.MapClaims{}
+
+	if _, err := v.parser.ParseWithClaims(token, claims, v.key); err != nil {
+		return Claims{}, fmt.Errorf("%s: %w", op, classify(err))
 	}
 
-	if !parsedToken.Valid {
-		log.Error("token is invalid", sl.Err(err))
+	email, ok := claims["email"].(string)
+	if !ok || email == "" {
+		return Claims{}, fmt.Errorf("%s: %w: email must be a non-empty string", op, ErrInvalidClaim)
+	}
 
-		return "", fmt.Errorf("%s: %w", op, err)
+	// Роль и uid необязательны: токены без роли считаются обычными пользователями
+	role, ok := claims["role"].(string)
+	if !ok && claims["role"] != nil {
+		return Claims{}, fmt.Errorf("%s: %w: role must be a string", op, ErrInvalidClaim)
+	}
+
+	var uid int64
+	if raw, present := claims["uid"]; present {
+		// Числа в JSON-claims декодируются как float64
+		n, ok := raw.(float64)
+		if !ok || n != float64(int64(n)) {
+			return Claims{}, fmt.Errorf("%s: %w: uid must be an integer", op, ErrInvalidClaim)
+		}
+		uid = int64(n)
 	}
 
-	return claims["email"].(string), nil
+	jti, ok := claims["jti"].(string)
+	if !ok && claims["jti"] != nil {
+		return Claims{}, fmt.Errorf("%s: %w: jti must be a string", op, ErrInvalidClaim)
+	}
+
+	// Типы exp и iat уже проверил парсер
+	exp, _ := claims.GetExpirationTime()
+	out := Claims{
+		Email:     email,
+		UserID:    uid,
+		Role:      role,
+		ID:        jti,
+		ExpiresAt: exp.Time,
+	}
+	if iat, _ := claims.GetIssuedAt(); iat != nil {
+		out.IssuedAt = iat.Time
+	}
+
+	return out, nil
+}
+
+func (v *Verifier) key(t *jwt.Token) (interface{}, error) {
+	if _, ok := t.Method.(*jwt.SigningMethodHMAC); ok {
+		return v.secret, nil
+	}
+
+	kid, _ := t.Header["kid"].(string)
+	return v.keys.Key(kid, t.Method.Alg())
+}
+
+func classify(err error) error {
+	var kind error
+	switch {
+	case errors.Is(err, ErrUnknownKey):
+		return err
+	case errors.Is(err, jwt.ErrTokenExpired):
+		kind = ErrExpired
+	case errors.Is(err, jwt.ErrTokenNotValidYet), errors.Is(err, jwt.ErrTokenUsedBeforeIssued):
+		kind = ErrNotYetValid
+	case errors.Is(err, jwt.ErrTokenInvalidIssuer):
+		kind = ErrInvalidIssuer
+	case errors.Is(err, jwt.ErrTokenInvalidAudience):
+		kind = ErrInvalidAudience
+	case errors.Is(err, jwt.ErrTokenSignatureInvalid):
+		kind = ErrInvalidSignature
+	case errors.Is(err, jwt.ErrTokenRequiredClaimMissing), errors.Is(err, jwt.ErrTokenInvalidClaims):
+		kind = ErrInvalidClaim
+	default:
+		kind = ErrMalformed
+	}
+	return fmt.Errorf("%w: %w", kind, err)
 }
@@ -0,0 +1,160 @@
+package jwt
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"testing"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	secret   = "test-secret"
+	issuer   = "auth-service"
+	audience = "post-service"
+	leeway   = time.Minute
+)
+
+func sign(t *testing.T, method gojwt.SigningMethod, kid string, key any, claims gojwt.MapClaims) string {
+	t.Helper()
+
+	token := gojwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestVerify(t *testing.T) {
+	keys := newTestKeys(t)
+
+	v, err := NewVerifier(Config{
+		Secret:   secret,
+		JWKSFile: writeJWKS(t, keys.jwks()...),
+		Issuer:   issuer,
+		Audience: audience,
+		Leeway:   leeway,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	claims := func(fields ...any) gojwt.MapClaims {
+		c := gojwt.MapClaims{
+			"email": "user@example.com",
+			"iss":   issuer,
+			"aud":   audience,
+			"exp":   now.Add(time.Hour).Unix(),
+		}
+		for i := 0; i+1 < len(fields); i += 2 {
+			name := fields[i].(string)
+			if fields[i+1] == nil {
+				delete(c, name)
+				continue
+			}
+			c[name] = fields[i+1]
+		}
+		return c
+	}
+
+	// Открытый ключ RSA в роли HMAC-секрета: классическая подмена RS256 на HS256
+	der, err := x509.MarshalPKIXPublicKey(&keys.rsa.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+
+	tests := []struct {
+		name  string
+		token string
+		want  error
+	}{
+		{"hs256", sign(t, gojwt.SigningMethodHS256, "", []byte(secret), claims()), nil},
+		{"rs256", sign(t, gojwt.SigningMethodRS256, "rsa", keys.rsa, claims()), nil},
+		{"es256", sign(t, gojwt.SigningMethodES256, "ec", keys.ec, claims()), nil},
+		{"eddsa", sign(t, gojwt.SigningMethodEdDSA, "ed", keys.ed, claims()), nil},
+
+		{"alg none", sign(t, gojwt.SigningMethodNone, "", gojwt.UnsafeAllowNoneSignatureType, claims()), ErrInvalidSignature},
+		{"hs384", sign(t, gojwt.SigningMethodHS384, "", []byte(secret), claims()), ErrInvalidSignature},
+		{"rs512", sign(t, gojwt.SigningMethodRS512, "rsa", keys.rsa, claims()), ErrInvalidSignature},
+		{"hs256 with the rsa key", sign(t, gojwt.SigningMethodHS256, "rsa", rsaPEM, claims()), ErrInvalidSignature},
+		{"hs256 with another secret", sign(t, gojwt.SigningMethodHS256, "", []byte("other"), claims()), ErrInvalidSignature},
+		{"rs256 with the ec kid", sign(t, gojwt.SigningMethodRS256, "ec", keys.rsa, claims()), ErrUnknownKey},
+		{"unknown kid", sign(t, gojwt.SigningMethodES256, "gone", keys.ec, claims()), ErrUnknownKey},
+		{"no kid among several keys", sign(t, gojwt.SigningMethodES256, "", keys.ec, claims()), ErrUnknownKey},
+
+		{"no exp", sign(t, gojwt.SigningMethodHS256, "", []byte(secret), claims("exp", nil)), ErrInvalidClaim},
+		{"expired", sign(t, gojwt.SigningMethodHS256, "", []byte(secret), claims("exp", now.Add(-2*leeway).Unix())), ErrExpired},
+		{"expired within leeway", sign(t, gojwt.SigningMethodHS256, "", []byte(secret), claims("exp", now.Add(-leeway/2).Unix())), nil},
+		{"not yet valid", sign(t, gojwt.SigningMethodHS256, "", []byte(secret), claims("nbf", now.Add(2*leeway).Unix())), ErrNotYetValid},
+		{"not yet valid within leeway", sign(t, gojwt.SigningMethodHS256, "", []byte(secret), claims("nbf", now.Add(leeway/2).Unix())), nil},
+
+		{"wrong issuer", sign(t, gojwt.SigningMethodHS256, "", []byte(secret), claims("iss", "someone")), ErrInvalidIssuer},
+		{"no issuer", sign(t, gojwt.SigningMethodHS256, "", []byte(secret), claims("iss", nil)), ErrInvalidClaim},
+		{"wrong audience", sign(t, gojwt.SigningMethodHS256, "", []byte(secret), claims("aud", "billing")), ErrInvalidAudience},
+		{"audience in a list", sign(t, gojwt.SigningMethodHS256, "", []byte(secret), claims("aud", []string{"billing", audience})), nil},
+
+		{"no email", sign(t, gojwt.SigningMethodHS256, "", []byte(secret), claims("email", nil)), ErrInvalidClaim},
+		{"role not a string", sign(t, gojwt.SigningMethodHS256, "", []byte(secret), claims("role", 1)), ErrInvalidClaim},
+		{"fractional uid", sign(t, gojwt.SigningMethodHS256, "", []byte(secret), claims("uid", 1.5)), ErrInvalidClaim},
+		{"garbage", "not.a.token", ErrMalformed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := v.Verify(tt.token)
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.want) {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	got, err := v.Verify(sign(t, gojwt.SigningMethodHS256, "", []byte(secret), claims("uid", 42, "role", RoleAdmin, "jti", "t1", "iat", now.Unix())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Email != "user@example.com" || got.UserID != 42 || !got.IsAdmin() || got.ID != "t1" ||
+		got.IssuedAt.Unix() != now.Unix() || got.ExpiresAt.Unix() != now.Add(time.Hour).Unix() {
+		t.Errorf("claims %+v", got)
+	}
+}
+
+func TestVerifyPinsConfiguredAlgorithms(t *testing.T) {
+	keys := newTestKeys(t)
+	now := time.Now()
+	claims := gojwt.MapClaims{"email": "user@example.com", "exp": now.Add(time.Hour).Unix()}
+
+	secretOnly, err := NewVerifier(Config{Secret: secret})
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwksOnly, err := NewVerifier(Config{JWKSFile: writeJWKS(t, keys.jwks()...)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := secretOnly.Verify(sign(t, gojwt.SigningMethodRS256, "rsa", keys.rsa, claims)); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("rs256 without JWKS: got %v", err)
+	}
+	// Без секрета HS256 не принимается вовсе, даже с пустым ключом
+	if _, err := jwksOnly.Verify(sign(t, gojwt.SigningMethodHS256, "", []byte{}, claims)); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("hs256 without secret: got %v", err)
+	}
+
+	if _, err := NewVerifier(Config{}); err == nil {
+		t.Error("verifier without keys created")
+	}
+}
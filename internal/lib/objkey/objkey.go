@@ -0,0 +1,29 @@
+package objkey
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// Prefix marks keys generated by New; anything else is a legacy key taken from the upload filename.
+const Prefix = "posts/"
+
+// New returns a fresh object key of the form posts/<author>/<random>, where <author>
+// is a digest of the email so that keys do not reveal it. The random part has 128 bits,
+// so keys never collide, whatever the uploaded files are called.
+func New(email string) (string, error) {
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return "", err
+	}
+
+	author := sha256.Sum256([]byte(strings.ToLower(email)))
+
+	return Prefix + hex.EncodeToString(author[:8]) + "/" + hex.EncodeToString(id[:]), nil
+}
+
+func IsLegacy(key string) bool {
+	return !strings.HasPrefix(key, Prefix)
+}
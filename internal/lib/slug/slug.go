@@ -0,0 +1,56 @@
+package slug
+
+import (
+	"crypto/rand"
+	"errors"
+)
+
+const alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// DefaultLength gives about 59 bits of entropy.
+const DefaultLength = 10
+
+var ErrInvalidLength = errors.New("slug length must be positive")
+
+// New returns a random base62 string of the given length. Bytes that would bias
+// the distribution are rejected rather than reduced modulo 62.
+func New(length int) (string, error) {
+	if length <= 0 {
+		return "", ErrInvalidLength
+	}
+
+	out := make([]byte, 0, length)
+	buf := make([]byte, length+length/2)
+
+	for len(out) < length {
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+		for _, b := range buf {
+			// 248 = 62*4, всё что выше даёт смещение
+			if b >= 248 {
+				continue
+			}
+			out = append(out, alphabet[b%62])
+			if len(out) == length {
+				break
+			}
+		}
+	}
+
+	return string(out), nil
+}
+
+// Valid reports whether s could have been produced by New.
+func Valid(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !('0' <= c && c <= '9' || 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z') {
+			return false
+		}
+	}
+	return true
+}
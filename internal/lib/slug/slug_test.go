@@ -0,0 +1,88 @@
+package slug
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	for _, length := range []int{1, DefaultLength, 100} {
+		s, err := New(length)
+		if err != nil {
+			t.Fatalf("New(%d): %v", length, err)
+		}
+		if len(s) != length {
+			t.Errorf("New(%d) has length %d", length, len(s))
+		}
+		if !Valid(s) {
+			t.Errorf("New(%d) = %q is not valid", length, s)
+		}
+	}
+
+	for _, length := range []int{0, -1} {
+		if _, err := New(length); !errors.Is(err, ErrInvalidLength) {
+			t.Errorf("New(%d): got %v, want ErrInvalidLength", length, err)
+		}
+	}
+}
+
+func TestNewDistribution(t *testing.T) {
+	const n = 62 * 2000
+
+	s, err := New(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counts := make(map[rune]int, len(alphabet))
+	for _, c := range s {
+		counts[c]++
+	}
+	if len(counts) != len(alphabet) {
+		t.Fatalf("%d of %d symbols used", len(counts), len(alphabet))
+	}
+	// Ожидается по 2000 каждого символа; смещение от взятия по модулю дало бы заметно больше у первых
+	for c, count := range counts {
+		if count < 1600 || count > 2400 {
+			t.Errorf("symbol %q appears %d times, want about 2000", c, count)
+		}
+	}
+}
+
+func TestValid(t *testing.T) {
+	tests := []struct {
+		s    string
+		want bool
+	}{
+		{"", false},
+		{"aZ09", true},
+		{alphabet, true},
+		{"a-b", false},
+		{"a/b", false},
+		{"../x", false},
+		{"ab c", false},
+		{"привет", false},
+		{strings.Repeat("x", 1000), true},
+	}
+
+	for _, tt := range tests {
+		if got := Valid(tt.s); got != tt.want {
+			t.Errorf("Valid(%q) = %v, want %v", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestNewUnique(t *testing.T) {
+	seen := make(map[string]bool)
+	for i := 0; i < 10000; i++ {
+		s, err := New(DefaultLength)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seen[s] {
+			t.Fatalf("duplicate slug %q", s)
+		}
+		seen[s] = true
+	}
+}
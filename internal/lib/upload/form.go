@@ -0,0 +1,120 @@
+package upload
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+)
+
+// FormOverhead is the room left in the request body for form fields and multipart boundaries.
+const FormOverhead = 1 << 20
+
+// maxFieldBytes bounds a single form field.
+const maxFieldBytes = 4 << 10
+
+var (
+	ErrMalformed = errors.New("malformed form")
+	ErrNoFile    = errors.New("file is missing")
+	ErrManyFiles = errors.New("only one file is allowed")
+)
+
+// Form streams a multipart upload with a single file. The fields are collected as
+// they come and the file part is handed out without buffering it.
+type Form struct {
+	mr      *multipart.Reader
+	err     error
+	maxSize int64
+	fields  map[string]string
+}
+
+// NewForm limits the body of r to a file of maxSize bytes plus FormOverhead. A body
+// that is not a multipart form is reported by NextFile.
+func NewForm(w http.ResponseWriter, r *http.Request, maxSize int64) *Form {
+	r.Body = http.MaxBytesReader(w, r.Body, maxSize+FormOverhead)
+
+	mr, err := r.MultipartReader()
+	return &Form{mr: mr, err: err, maxSize: maxSize, fields: make(map[string]string)}
+}
+
+// NextFile reads the fields preceding the file part and returns it.
+func (f *Form) NextFile() (*multipart.Part, error) {
+	part, err := f.nextFile()
+	if errors.Is(err, io.EOF) {
+		return nil, ErrNoFile
+	}
+	return part, err
+}
+
+// Open wraps the file part in a Reader that enforces the size limit. Opaque
+// content is not sniffed, see NewOpaqueReader.
+func (f *Form) Open(part *multipart.Part, opaque bool) (*Reader, error) {
+	if opaque {
+		return NewOpaqueReader(part, f.maxSize)
+	}
+
+	body, err := NewReader(part, part.FileName(), f.maxSize)
+	if err != nil && !errors.Is(err, ErrTooLarge) {
+		return nil, fmt.Errorf("%w: %w", ErrMalformed, err)
+	}
+	return body, err
+}
+
+// Rest reads the fields that follow the file; another file is ErrManyFiles.
+func (f *Form) Rest() error {
+	_, err := f.nextFile()
+	switch {
+	case errors.Is(err, io.EOF):
+		return nil
+	case err != nil:
+		return err
+	}
+	return ErrManyFiles
+}
+
+// Value returns the field read so far, empty when the form has not sent it.
+func (f *Form) Value(name string) string {
+	return f.fields[name]
+}
+
+func (f *Form) nextFile() (*multipart.Part, error) {
+	if f.err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrMalformed, f.err)
+	}
+	for {
+		part, err := f.mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			return nil, io.EOF
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrMalformed, err)
+		}
+		if part.FileName() != "" {
+			return part, nil
+		}
+
+		value, err := io.ReadAll(io.LimitReader(part, maxFieldBytes))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrMalformed, err)
+		}
+		f.fields[part.FormName()] = string(value)
+	}
+}
+
+// Status maps the errors of reading an upload form to the response status and
+// message. Other errors are reported as internal with the fallback message.
+func Status(err error, fallback string) (int, string) {
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.Is(err, ErrTooLarge), errors.As(err, &maxBytesErr):
+		return http.StatusRequestEntityTooLarge, ErrTooLarge.Error()
+	case errors.Is(err, ErrNoFile):
+		return http.StatusBadRequest, ErrNoFile.Error()
+	case errors.Is(err, ErrManyFiles):
+		return http.StatusBadRequest, ErrManyFiles.Error()
+	case errors.Is(err, ErrMalformed):
+		return http.StatusBadRequest, "failed to decode request"
+	}
+	return http.StatusInternalServerError, fallback
+}
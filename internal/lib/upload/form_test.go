@@ -0,0 +1,128 @@
+package upload
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const maxSize = 1 << 10
+
+// part is a form field, or a file when file is set.
+type part struct {
+	name, value string
+	file        bool
+}
+
+func formRequest(t *testing.T, parts ...part) *http.Request {
+	t.Helper()
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	for _, p := range parts {
+		var w io.Writer
+		var err error
+		if p.file {
+			w, err = mw.CreateFormFile(p.name, "a.txt")
+		} else {
+			w, err = mw.CreateFormField(p.name)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(w, p.value)
+	}
+	if err := mw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/", &buf)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	return r
+}
+
+// upload reads the form the way the handlers do and returns the file and the first error.
+func upload(r *http.Request) (*Form, string, error) {
+	form := NewForm(httptest.NewRecorder(), r, maxSize)
+
+	part, err := form.NextFile()
+	if err != nil {
+		return form, "", err
+	}
+	body, err := form.Open(part, false)
+	if err != nil {
+		return form, "", err
+	}
+	content, err := io.ReadAll(body)
+	if err != nil {
+		return form, "", body.Cause(err)
+	}
+	return form, string(content), form.Rest()
+}
+
+func TestForm(t *testing.T) {
+	tests := []struct {
+		name   string
+		req    *http.Request
+		err    error
+		status int
+		title  string
+	}{
+		{
+			name:  "fields around the file",
+			req:   formRequest(t, part{name: "title", value: "a"}, part{name: "file", value: "text", file: true}, part{name: "syntax", value: "go"}),
+			title: "a",
+		},
+		{
+			name:   "no file",
+			req:    formRequest(t, part{name: "title", value: "a"}),
+			err:    ErrNoFile,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "two files",
+			req:    formRequest(t, part{name: "file", value: "a", file: true}, part{name: "file", value: "b", file: true}),
+			err:    ErrManyFiles,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "file too large",
+			req:    formRequest(t, part{name: "file", value: strings.Repeat("x", maxSize+1), file: true}),
+			err:    ErrTooLarge,
+			status: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:   "not a form",
+			req:    httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"title":"a"}`)),
+			err:    ErrMalformed,
+			status: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form, content, err := upload(tt.req)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				if status, _ := Status(err, "failed"); status != tt.status {
+					t.Errorf("status %d, want %d", status, tt.status)
+				}
+				return
+			}
+			if content != "text" || form.Value("title") != tt.title || form.Value("syntax") != "go" {
+				t.Errorf("got file %q, title %q, syntax %q", content, form.Value("title"), form.Value("syntax"))
+			}
+		})
+	}
+
+	if status, msg := Status(errors.New("store is down"), "failed to upload file"); status != http.StatusInternalServerError || msg != "failed to upload file" {
+		t.Errorf("other errors: got %d %q", status, msg)
+	}
+}
@@ -0,0 +1,138 @@
+package upload
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// sniffLen is how much http.DetectContentType looks at.
+const sniffLen = 512
+
+// OpaqueContentType is recorded for content that must not be inspected.
+const OpaqueContentType = "application/octet-stream"
+
+var ErrTooLarge = errors.New("file too large")
+
+// Reader passes the uploaded content through while enforcing the size limit
+// and collecting the metadata stored with the post.
+type Reader struct {
+	r           io.Reader
+	limit       *limitedReader
+	hash        hash.Hash
+	size        int64
+	contentType string
+}
+
+// NewReader sniffs the content type from the first bytes of r, falling back to the
+// filename extension when the content alone is not conclusive.
+func NewReader(r io.Reader, filename string, maxSize int64) (*Reader, error) {
+	limit := &limitedReader{r: r, n: maxSize}
+	buffered := bufio.NewReaderSize(limit, sniffLen)
+
+	head, err := buffered.Peek(sniffLen)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	h := sha256.New()
+
+	return &Reader{
+		r:           io.TeeReader(buffered, h),
+		limit:       limit,
+		hash:        h,
+		contentType: DetectContentType(head, filename),
+	}, nil
+}
+
+// NewOpaqueReader is NewReader for client-encrypted content: nothing is sniffed
+// and the content type is always OpaqueContentType.
+func NewOpaqueReader(r io.Reader, maxSize int64) (*Reader, error) {
+	limit := &limitedReader{r: r, n: maxSize}
+	h := sha256.New()
+
+	return &Reader{
+		r:           io.TeeReader(limit, h),
+		limit:       limit,
+		hash:        h,
+		contentType: OpaqueContentType,
+	}, nil
+}
+
+func (r *Reader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// Exceeded reports whether reading stopped because the size limit was hit.
+func (r *Reader) Exceeded() bool {
+	return r.limit.exceeded
+}
+
+// Cause returns ErrTooLarge when reading stopped at the size limit, however the
+// blob store reported it, and err otherwise.
+func (r *Reader) Cause(err error) error {
+	if r.Exceeded() {
+		return fmt.Errorf("%w: %w", ErrTooLarge, err)
+	}
+	return err
+}
+
+func (r *Reader) Size() int64 {
+	return r.size
+}
+
+func (r *Reader) ContentType() string {
+	return r.contentType
+}
+
+// Checksum is the hex SHA-256 of everything read so far.
+func (r *Reader) Checksum() string {
+	return hex.EncodeToString(r.hash.Sum(nil))
+}
+
+func DetectContentType(head []byte, filename string) string {
+	detected := http.DetectContentType(head)
+
+	generic := detected == "application/octet-stream" || strings.HasPrefix(detected, "text/plain")
+	if generic {
+		if byExt := mime.TypeByExtension(path.Ext(filename)); byExt != "" {
+			return byExt
+		}
+	}
+	return detected
+}
+
+// limitedReader fails the read once more than n bytes were consumed,
+// which aborts an in-flight upload instead of silently truncating it.
+type limitedReader struct {
+	r        io.Reader
+	n        int64
+	exceeded bool
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.n < 0 {
+		l.exceeded = true
+		return 0, ErrTooLarge
+	}
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	if l.n < 0 {
+		l.exceeded = true
+		return n, ErrTooLarge
+	}
+	return n, err
+}
@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// API token scopes. A token may only be used on the routes its scopes cover.
+const (
+	ScopeRead   = "read"
+	ScopeWrite  = "write"
+	ScopeDelete = "delete"
+)
+
+func ValidScope(s string) bool {
+	switch s {
+	case ScopeRead, ScopeWrite, ScopeDelete:
+		return true
+	}
+	return false
+}
+
+// APIToken is a long-lived personal token for scripts. Prefix is the start of
+// the token, enough to recognise it in a list; the token itself is not stored.
+type APIToken struct {
+	ID         int64      `json:"id" db:"id"`
+	Email      string     `json:"-" db:"email"`
+	Name       string     `json:"name" db:"name"`
+	Prefix     string     `json:"prefix" db:"prefix"`
+	Scopes     []string   `json:"scopes" db:"-"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty" db:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty" db:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+}
+
+func (t APIToken) Active(now time.Time) bool {
+	return t.RevokedAt == nil && (t.ExpiresAt == nil || now.Before(*t.ExpiresAt))
+}
@@ -0,0 +1,14 @@
+package models
+
+const (
+	DeleteStatusDeleted   = "deleted"
+	DeleteStatusNotFound  = "not_found"
+	DeleteStatusForbidden = "forbidden"
+)
+
+type DeleteResult struct {
+	ID     int    `json:"id"`
+	Status string `json:"status"`
+	// Keys are the objects of all revisions of the deleted post
+	Keys []string `json:"-"`
+}
@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+type FeedItem struct {
+	ID        int64     `json:"id" db:"id"`
+	PostID    int       `json:"post_id" db:"post_id"`
+	Slug      string    `json:"slug" db:"slug"`
+	Author    string    `json:"author" db:"author"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
@@ -0,0 +1,19 @@
+package models
+
+// PostRef names another post without exposing its id.
+type PostRef struct {
+	Slug  string `json:"slug"`
+	Title string `json:"title"`
+	Email string `json:"email"`
+}
+
+// Lineage is where a post was forked from, nearest parent first, and how many
+// times it has been forked itself. Ancestors the viewer cannot see end the chain.
+type Lineage struct {
+	Ancestors []PostRef `json:"ancestors"`
+	Forks     int       `json:"forks"`
+}
+
+func (p PostUser) Ref() PostRef {
+	return PostRef{Slug: p.Slug, Title: p.Title, Email: p.Email}
+}
@@ -0,0 +1,9 @@
+package models
+
+type OutboxEvent struct {
+	ID       int64
+	Topic    string
+	Key      string
+	Payload  []byte
+	Attempts int
+}
@@ -1,5 +1,8 @@
 package models
 
+// PostsTopic is the Kafka topic post-created events are published to.
+const PostsTopic = "posts"
+
 type Post struct {
 	PostID int    `json:"post_id"`
 	Email  string `json:"email"`
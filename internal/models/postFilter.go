@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// PostFilter selects a page of posts ordered by id: Limit posts with id greater
+// than AfterID, optionally restricted to one author and a creation time range.
+// Only public posts and the Viewer's own posts are listed.
+type PostFilter struct {
+	Viewer      string
+	Email       string
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	AfterID     int64
+	Limit       int
+}
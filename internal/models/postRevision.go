@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// PostRevision is one immutable version of a post body. Restoring an old
+// revision adds a new one that points at the same object.
+type PostRevision struct {
+	PostID      int64     `json:"post_id" db:"post_id"`
+	Revision    int       `json:"revision" db:"revision"`
+	Key         string    `json:"-" db:"key"`
+	FileName    string    `json:"file_name" db:"filename"`
+	ContentType string    `json:"content_type" db:"content_type"`
+	Size        int64     `json:"size" db:"size"`
+	Checksum    string    `json:"checksum" db:"checksum"`
+	EncKeyID    string    `json:"-" db:"enc_key_id"`
+	EncDataKey  []byte    `json:"-" db:"enc_data_key"`
+	Author      string    `json:"author" db:"author"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+}
+
+// AtRevision returns the post as it was at revision r.
+func (p PostUser) AtRevision(r PostRevision) PostUser {
+	p.Revision = r.Revision
+	p.Key = r.Key
+	p.FileName = r.FileName
+	p.ContentType = r.ContentType
+	p.Size = r.Size
+	p.Checksum = r.Checksum
+	p.EncKeyID = r.EncKeyID
+	p.EncDataKey = r.EncDataKey
+	p.UpdatedAt = r.CreatedAt
+	return p
+}
@@ -1,8 +1,49 @@
 package models
 
+import "time"
+
+// PostUser is a post as stored in users_posts. Checksum is the hex SHA-256
+// of the content; ExpiresAt is nil for posts that never expire. A BurnAfterRead
+// post gets ConsumedAt set by its first successful download. PasswordHash is
+// the bcrypt hash of the optional download password. EncKeyID and EncDataKey are
+// set for server-side encrypted bodies: the id of the master key and the data key
+// wrapped by it. ClientEncrypted bodies are opaque and are never inspected.
+// ParentID is the post this one was forked from, nil for original posts.
+// Syntax is the language chosen at upload for highlighting, empty to detect it.
 type PostUser struct {
-	ID     int64  `json:"id"`
-	Email  string `json:"email"`
-	Bucket string `json:"bucket"`
-	Key    string `json:"key"`
+	ID              int64      `json:"id" db:"id"`
+	Slug            string     `json:"slug" db:"slug"`
+	Email           string     `json:"email" db:"email"`
+	Bucket          string     `json:"bucket" db:"bucket"`
+	Key             string     `json:"key" db:"key"`
+	Title           string     `json:"title" db:"title"`
+	FileName        string     `json:"file_name" db:"filename"`
+	ContentType     string     `json:"content_type" db:"content_type"`
+	Size            int64      `json:"size" db:"size"`
+	Checksum        string     `json:"checksum" db:"checksum"`
+	CreatedAt       time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at" db:"updated_at"`
+	ExpiresAt       *time.Time `json:"expires_at,omitempty" db:"expires_at"`
+	BurnAfterRead   bool       `json:"burn_after_read" db:"burn_after_read"`
+	ConsumedAt      *time.Time `json:"consumed_at,omitempty" db:"consumed_at"`
+	Visibility      string     `json:"visibility" db:"visibility"`
+	PasswordHash    []byte     `json:"-" db:"password_hash"`
+	EncKeyID        string     `json:"-" db:"enc_key_id"`
+	EncDataKey      []byte     `json:"-" db:"enc_data_key"`
+	ClientEncrypted bool       `json:"client_encrypted" db:"client_encrypted"`
+	Revision        int        `json:"revision" db:"revision"`
+	ParentID        *int64     `json:"-" db:"parent_id"`
+	Syntax          string     `json:"syntax" db:"syntax"`
+}
+
+func (p PostUser) Protected() bool {
+	return len(p.PasswordHash) > 0
+}
+
+func (p PostUser) Encrypted() bool {
+	return p.EncKeyID != ""
+}
+
+func (p PostUser) Expired(now time.Time) bool {
+	return p.ExpiresAt != nil && !now.Before(*p.ExpiresAt)
 }
@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// PostView is a post as shown to its readers. Where the content is stored, the
+// password hash, the key material and the parent id stay internal.
+type PostView struct {
+	ID              int64      `json:"id"`
+	Slug            string     `json:"slug"`
+	Email           string     `json:"email"`
+	Title           string     `json:"title"`
+	FileName        string     `json:"file_name"`
+	ContentType     string     `json:"content_type"`
+	Size            int64      `json:"size"`
+	Checksum        string     `json:"checksum"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+	ExpiresAt       *time.Time `json:"expires_at,omitempty"`
+	BurnAfterRead   bool       `json:"burn_after_read"`
+	Visibility      string     `json:"visibility"`
+	Protected       bool       `json:"protected"`
+	ClientEncrypted bool       `json:"client_encrypted"`
+	Revision        int        `json:"revision"`
+	Syntax          string     `json:"syntax"`
+}
+
+func (p PostUser) View() PostView {
+	return PostView{
+		ID:              p.ID,
+		Slug:            p.Slug,
+		Email:           p.Email,
+		Title:           p.Title,
+		FileName:        p.FileName,
+		ContentType:     p.ContentType,
+		Size:            p.Size,
+		Checksum:        p.Checksum,
+		CreatedAt:       p.CreatedAt,
+		UpdatedAt:       p.UpdatedAt,
+		ExpiresAt:       p.ExpiresAt,
+		BurnAfterRead:   p.BurnAfterRead,
+		Visibility:      p.Visibility,
+		Protected:       p.Protected(),
+		ClientEncrypted: p.ClientEncrypted,
+		Revision:        p.Revision,
+		Syntax:          p.Syntax,
+	}
+}
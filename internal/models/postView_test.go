@@ -0,0 +1,38 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestPostViewHidesInternals(t *testing.T) {
+	parent := int64(7)
+	post := PostUser{
+		ID:           1,
+		Slug:         "abc",
+		Bucket:       "bucket-name",
+		Key:          "object-key",
+		PasswordHash: []byte("hash"),
+		EncKeyID:     "master-1",
+		EncDataKey:   []byte("wrapped"),
+		ParentID:     &parent,
+	}
+
+	data, err := json.Marshal(post.View())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"bucket", "key", "password_hash", "enc_key_id", "enc_data_key", "parent_id"} {
+		if _, ok := fields[name]; ok {
+			t.Errorf("view exposes %q: %s", name, data)
+		}
+	}
+	if fields["protected"] != true || fields["slug"] != "abc" {
+		t.Errorf("view lost public fields: %s", data)
+	}
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// RevokedToken is a JWT revoked by its jti. It is kept until ExpiresAt, after
+// which the token is rejected as expired anyway.
+type RevokedToken struct {
+	JTI       string    `json:"jti" db:"jti"`
+	ExpiresAt time.Time `json:"expires_at" db:"expires_at"`
+}
+
+// RevokedUser revokes every token of Email issued before RevokedBefore.
+type RevokedUser struct {
+	Email         string    `json:"email" db:"email"`
+	RevokedBefore time.Time `json:"revoked_before" db:"revoked_before"`
+}
@@ -1,6 +0,0 @@
-package models
-
-type Subscriptions struct {
-	UID   int `json:"uid"`
-	SubID int `json:"sub_id"`
-}
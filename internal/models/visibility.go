@@ -0,0 +1,19 @@
+package models
+
+// Visibility decides who can read a post and whether it is listed:
+// public posts are listed for everyone, unlisted ones are readable by id only,
+// followers-only ones by the author's subscribers, private ones by the author alone.
+const (
+	VisibilityPublic    = "public"
+	VisibilityUnlisted  = "unlisted"
+	VisibilityFollowers = "followers"
+	VisibilityPrivate   = "private"
+)
+
+func ValidVisibility(v string) bool {
+	switch v {
+	case VisibilityPublic, VisibilityUnlisted, VisibilityFollowers, VisibilityPrivate:
+		return true
+	}
+	return false
+}
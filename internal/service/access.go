@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/maestro-milagro/Post_Service_PB/internal/lib/slug"
+	"github.com/maestro-milagro/Post_Service_PB/internal/models"
+	"github.com/maestro-milagro/Post_Service_PB/internal/storage"
+	"strconv"
+	"time"
+)
+
+var ErrInvalidID = errors.New("invalid post id")
+
+// PostRef names the post a request is for: by its public Slug or, on the
+// owner-only routes, by ID, both as they appear in the URL.
+type PostRef struct {
+	Slug string
+	ID   string
+}
+
+// LockedError reports a post locked after wrong passwords. It matches ErrTooManyAttempts.
+type LockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockedError) Error() string {
+	return ErrTooManyAttempts.Error()
+}
+
+func (e *LockedError) Unwrap() error {
+	return ErrTooManyAttempts
+}
+
+// Resolve finds the post named by ref and applies every check its reader must
+// pass: visibility, expiry and the download password. Burn-after-read posts are
+// not consumed, so Resolve suits the callers that do not hand the content out.
+func (s *Service) Resolve(ctx context.Context, viewer string, ref PostRef, password string) (models.PostUser, error) {
+	const op = "service.Resolve"
+
+	post, err := s.lookup(ctx, viewer, ref)
+	if err != nil {
+		return models.PostUser{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	wait, err := s.gate.Check(post, viewer, password)
+	if err != nil {
+		if errors.Is(err, ErrTooManyAttempts) {
+			err = &LockedError{RetryAfter: wait}
+		}
+		return models.PostUser{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return post, nil
+}
+
+// Open resolves the post like Resolve for reading its content. A burn-after-read
+// post is consumed at once; release gives the read back when the content could
+// not be delivered. For other posts release does nothing.
+func (s *Service) Open(ctx context.Context, viewer string, ref PostRef, password string) (models.PostUser, func(), error) {
+	const op = "service.Open"
+
+	post, err := s.Resolve(ctx, viewer, ref, password)
+	if err != nil {
+		return models.PostUser{}, nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if !post.BurnAfterRead {
+		return post, func() {}, nil
+	}
+
+	// Пост «сгорает» при первом успешном чтении: забираем право на чтение атомарно в БД
+	release, err := s.Consume(ctx, int(post.ID))
+	if err != nil {
+		return models.PostUser{}, nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return post, release, nil
+}
+
+func (s *Service) lookup(ctx context.Context, viewer string, ref PostRef) (models.PostUser, error) {
+	if ref.Slug != "" {
+		if !slug.Valid(ref.Slug) {
+			return models.PostUser{}, storage.ErrPostNotFound
+		}
+		return s.GetBySlug(ctx, viewer, ref.Slug)
+	}
+
+	id, err := strconv.Atoi(ref.ID)
+	if err != nil {
+		return models.PostUser{}, fmt.Errorf("%w: %w", ErrInvalidID, err)
+	}
+	return s.GetById(ctx, viewer, id)
+}
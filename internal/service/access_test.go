@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/maestro-milagro/Post_Service_PB/internal/models"
+	"github.com/maestro-milagro/Post_Service_PB/internal/storage"
+)
+
+// postsStore serves posts by id and by slug and records consumptions.
+type postsStore struct {
+	posts    map[int64]models.PostUser
+	consumed map[int]bool
+}
+
+func (s *postsStore) GetByIdDB(_ context.Context, id int) (models.PostUser, error) {
+	post, ok := s.posts[int64(id)]
+	if !ok {
+		return models.PostUser{}, storage.ErrPostNotFound
+	}
+	return post, nil
+}
+
+func (s *postsStore) GetBySlugDB(_ context.Context, slug string) (models.PostUser, error) {
+	for _, post := range s.posts {
+		if post.Slug == slug {
+			return post, nil
+		}
+	}
+	return models.PostUser{}, storage.ErrPostNotFound
+}
+
+func (s *postsStore) ConsumeDB(_ context.Context, id int) (time.Time, error) {
+	if s.consumed[id] {
+		return time.Time{}, storage.ErrPostConsumed
+	}
+	s.consumed[id] = true
+	return time.Now(), nil
+}
+
+func (s *postsStore) ReleaseConsumeDB(_ context.Context, id int, _ time.Time) error {
+	delete(s.consumed, id)
+	return nil
+}
+
+func TestServiceOpen(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	protected := protectedPost(t, "secret")
+	protected.Slug, protected.Visibility = "locked", models.VisibilityPublic
+
+	store := &postsStore{
+		posts: map[int64]models.PostUser{
+			1: protected,
+			2: {ID: 2, Email: "author@example.com", Slug: "burn", Visibility: models.VisibilityPublic, BurnAfterRead: true},
+			3: {ID: 3, Email: "author@example.com", Slug: "private", Visibility: models.VisibilityPrivate},
+		},
+		consumed: map[int]bool{},
+	}
+	s := &Service{
+		log:          log,
+		dbByIDGetter: store,
+		dbBySlug:     store,
+		dbBurner:     store,
+		gate:         NewPasswordGate(log, 1, time.Minute),
+	}
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		ref      PostRef
+		password string
+		want     error
+	}{
+		{"bad id", PostRef{ID: "x"}, "", ErrInvalidID},
+		{"bad slug", PostRef{Slug: "../x"}, "", storage.ErrPostNotFound},
+		{"id of another user", PostRef{ID: "3"}, "", storage.ErrPostNotFound},
+		{"hidden slug", PostRef{Slug: "private"}, "", storage.ErrPostNotFound},
+		{"no password", PostRef{Slug: "locked"}, "", ErrPasswordRequired},
+		{"wrong password", PostRef{Slug: "locked"}, "wrong", ErrWrongPassword},
+		// Одна неверная попытка уже исчерпала лимит
+		{"locked", PostRef{Slug: "locked"}, "secret", ErrTooManyAttempts},
+		{"burn", PostRef{Slug: "burn"}, "", nil},
+		{"burnt", PostRef{Slug: "burn"}, "", ErrPostConsumed},
+	}
+
+	for _, tt := range tests {
+		_, release, err := s.Open(ctx, "reader@example.com", tt.ref, tt.password)
+		if !errors.Is(err, tt.want) {
+			t.Fatalf("%s: got %v, want %v", tt.name, err, tt.want)
+		}
+		if err == nil && release == nil {
+			t.Fatalf("%s: release is nil", tt.name)
+		}
+	}
+
+	var locked *LockedError
+	_, _, err := s.Open(ctx, "reader@example.com", PostRef{Slug: "locked"}, "secret")
+	if !errors.As(err, &locked) || locked.RetryAfter <= 0 {
+		t.Errorf("locked post: got %v, want LockedError with RetryAfter", err)
+	}
+
+	// Возврат права на чтение снова открывает одноразовый пост
+	store.consumed = map[int]bool{}
+	_, release, err := s.Open(ctx, "reader@example.com", PostRef{Slug: "burn"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+	if _, _, err := s.Open(ctx, "reader@example.com", PostRef{Slug: "burn"}, ""); err != nil {
+		t.Errorf("after release: %v", err)
+	}
+
+	// Resolve проверяет доступ, но пост не «сжигает»
+	store.consumed = map[int]bool{}
+	for i := 0; i < 2; i++ {
+		if _, err := s.Resolve(ctx, "reader@example.com", PostRef{Slug: "burn"}, ""); err != nil {
+			t.Fatalf("Resolve: %v", err)
+		}
+	}
+	if store.consumed[2] {
+		t.Error("Resolve consumed the post")
+	}
+
+	// Автор открывает свой пост по id без пароля
+	if _, _, err := s.Open(ctx, "author@example.com", PostRef{ID: "1"}, ""); err != nil {
+		t.Errorf("author by id: %v", err)
+	}
+}
@@ -1,16 +1,21 @@
 package aws
 
 import (
-	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/maestro-milagro/Post_Service_PB/internal/lib/sl"
+	"github.com/maestro-milagro/Post_Service_PB/internal/service"
+	"io"
 	"log/slog"
+	"net/url"
+	"strings"
 )
 
 type AwsService struct {
@@ -18,119 +23,200 @@ type AwsService struct {
 	Client *s3.Client
 }
 
-//type CrdnProvider struct {
-//}
-//
-//func (c *CrdnProvider) Retrieve() (credentials.Value, error) {
-//	return credentials.Value{
-//		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
-//		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
-//		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
-//		ProviderName:    "CrdnProvider",
-//	}, nil
-//}
-//
-//// IsExpired returns if the credentials are no longer valid, and need
-//// to be retrieved.
-//func (c *CrdnProvider) IsExpired() bool {
-//
-//}
-
-func New(log *slog.Logger) *AwsService {
-	// Создаем кастомный обработчик эндпоинтов, который для сервиса S3 и региона ru-central1 выдаст корректный URL
-	customResolver := aws.EndpointResolverWithOptionsFunc(func(service, region string, options ...interface{}) (aws.Endpoint, error) {
-		if service == s3.ServiceID && region == "ru-central1" {
-			return aws.Endpoint{
-				PartitionID:   "yc",
-				URL:           "https://storage.yandexcloud.net",
-				SigningRegion: "ru-central1",
-			}, nil
-		}
-		return aws.Endpoint{}, fmt.Errorf("unknown endpoint requested")
-	})
+type Config struct {
+	Endpoint        string
+	Region          string
+	UsePathStyle    bool
+	AccessKeyID     string
+	SecretAccessKey string
+}
 
-	// Подгружаем конфигурацию из ~/.aws/*
-	cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithEndpointResolverWithOptions(customResolver))
-	if err != nil {
-		log.Error("failed to load configuration", sl.Err(err))
+func New(log *slog.Logger, cfg Config) (*AwsService, error) {
+	const op = "service.aws.New"
 
-		return nil
+	opts := []func(*config.LoadOptions) error{
+		config.WithRegion(cfg.Region),
+	}
+	// Без явных ключей используем стандартную цепочку: переменные окружения, ~/.aws/*
+	if cfg.AccessKeyID != "" {
+		opts = append(opts, config.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(cfg.AccessKeyID, cfg.SecretAccessKey, ""),
+		))
 	}
 
-	// Создаем клиента для доступа к хранилищу S3
-	client := s3.NewFromConfig(cfg)
+	awsCfg, err := config.LoadDefaultConfig(context.TODO(), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Создаем клиента для доступа к S3-совместимому хранилищу (Yandex Cloud, MinIO, AWS)
+	client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		if cfg.Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.Endpoint)
+		}
+		o.UsePathStyle = cfg.UsePathStyle
+	})
 
 	return &AwsService{
 		log:    log,
 		Client: client,
-	}
+	}, nil
 }
 
-func (a *AwsService) UploadFile(bucketName string, fileName string, largeObject []byte) error {
-	//file, err := os.Open(fileName)
-	//if err != nil {
-	//	a.log.Error("Couldn't open file %v to upload. Here's why: %v\n", fileName, err)
-	//} else {
-	//		defer file.Close()
-	largeBuffer := bytes.NewReader(largeObject)
-	///		var partMiBs int64 = 10
-	uploader := manager.NewUploader(a.Client)
-	//	, func(u *manager.Uploader) {
-	//		//			u.PartSize = partMiBs * 1024 * 1024
-	//	}
-	_, err := uploader.Upload(context.TODO(), &s3.PutObjectInput{
+func (a *AwsService) UploadFile(ctx context.Context, bucketName string, fileName string, body io.Reader, contentType string) error {
+	const op = "service.aws.UploadFile"
+
+	input := &s3.PutObjectInput{
 		Bucket: aws.String(bucketName),
 		Key:    aws.String(fileName),
-		Body:   largeBuffer,
-	})
+		Body:   body,
+	}
+	if contentType != "" {
+		input.ContentType = aws.String(contentType)
+	}
+
+	// Uploader читает body частями, поэтому в памяти держится не больше PartSize*Concurrency байт
+	uploader := manager.NewUploader(a.Client)
+	_, err := uploader.Upload(ctx, input)
 	if err != nil {
-		a.log.Error("Couldn't upload file %v to %v. Here's why: %v\n",
-			fileName, bucketName, err)
+		a.log.Error("couldn't upload file",
+			slog.String("bucket", bucketName),
+			slog.String("key", fileName),
+			sl.Err(err),
+		)
+
+		return fmt.Errorf("%s: %w", op, err)
 	}
-	//	}
-	return err
+	return nil
 }
 
-func (a *AwsService) DownloadFile(bucketName string, filename string) ([]byte, error) {
-	downloader := manager.NewDownloader(a.Client)
-	buffer := manager.NewWriteAtBuffer([]byte{})
-	_, err := downloader.Download(context.TODO(), buffer, &s3.GetObjectInput{
+func (a *AwsService) StatObject(ctx context.Context, bucketName string, key string) (service.ObjectInfo, error) {
+	const op = "service.aws.StatObject"
+
+	out, err := a.Client.HeadObject(ctx, &s3.HeadObjectInput{
 		Bucket: aws.String(bucketName),
-		Key:    aws.String(filename),
+		Key:    aws.String(key),
 	})
 	if err != nil {
-		a.log.Error("Couldn't download large object from %v:%v. Here's why: %v\n",
-			bucketName, filename, err)
+		var notFound *types.NotFound
+		if errors.As(err, &notFound) {
+			return service.ObjectInfo{}, fmt.Errorf("%s: %w", op, service.ErrObjectNotFound)
+		}
+		return service.ObjectInfo{}, fmt.Errorf("%s: %w", op, err)
 	}
-	return buffer.Bytes(), err
+
+	return service.ObjectInfo{
+		Size:         aws.ToInt64(out.ContentLength),
+		ContentType:  aws.ToString(out.ContentType),
+		ETag:         aws.ToString(out.ETag),
+		LastModified: aws.ToTime(out.LastModified),
+	}, nil
 }
 
-func (a *AwsService) DownloadList(bucketName string) ([]types.Object, error) {
-	result, err := a.Client.ListObjectsV2(context.TODO(), &s3.ListObjectsV2Input{
+func (a *AwsService) OpenObject(ctx context.Context, bucketName string, key string, offset int64, length int64) (io.ReadCloser, error) {
+	const op = "service.aws.OpenObject"
+
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(key),
+	}
+	switch {
+	case length == 0:
+		return io.NopCloser(strings.NewReader("")), nil
+	case length > 0:
+		input.Range = aws.String(fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
+	case offset > 0:
+		input.Range = aws.String(fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	out, err := a.Client.GetObject(ctx, input)
+	if err != nil {
+		var noSuchKey *types.NoSuchKey
+		if errors.As(err, &noSuchKey) {
+			return nil, fmt.Errorf("%s: %w", op, service.ErrObjectNotFound)
+		}
+		a.log.Error("couldn't open object",
+			slog.String("bucket", bucketName),
+			slog.String("key", key),
+			sl.Err(err),
+		)
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return out.Body, nil
+}
+
+func (a *AwsService) DownloadList(ctx context.Context, bucketName string) ([]types.Object, error) {
+	const op = "service.aws.DownloadList"
+
+	result, err := a.Client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
 		Bucket: aws.String(bucketName),
 	})
-	var contents []types.Object
 	if err != nil {
-		a.log.Error("Couldn't list objects in bucket %v. Here's why: %v\n", bucketName, err)
-	} else {
-		contents = result.Contents
+		a.log.Error("couldn't list objects", slog.String("bucket", bucketName), sl.Err(err))
+
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
-	return contents, err
+	return result.Contents, nil
 }
 
-func (a *AwsService) DeleteObjects(bucketName string, objectKeys []string) error {
+func (a *AwsService) DeleteObjects(ctx context.Context, bucketName string, objectKeys []string) error {
+	const op = "service.aws.DeleteObjects"
+
 	var objectIds []types.ObjectIdentifier
 	for _, key := range objectKeys {
 		objectIds = append(objectIds, types.ObjectIdentifier{Key: aws.String(key)})
 	}
-	output, err := a.Client.DeleteObjects(context.TODO(), &s3.DeleteObjectsInput{
+	output, err := a.Client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
 		Bucket: aws.String(bucketName),
 		Delete: &types.Delete{Objects: objectIds},
 	})
 	if err != nil {
-		a.log.Error("Couldn't delete objects from bucket %v. Here's why: %v\n", bucketName, err)
-	} else {
-		a.log.Info("Deleted %v objects.\n", len(output.Deleted))
+		a.log.Error("couldn't delete objects", slog.String("bucket", bucketName), sl.Err(err))
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	a.log.Info("deleted objects", slog.Int("count", len(output.Deleted)))
+
+	if len(output.Errors) > 0 {
+		first := output.Errors[0]
+
+		return fmt.Errorf("%s: %d objects not deleted, first %s: %s",
+			op, len(output.Errors), aws.ToString(first.Key), aws.ToString(first.Message))
+	}
+	return nil
+}
+
+func (a *AwsService) CopyObject(ctx context.Context, bucketName string, srcKey string, dstKey string) error {
+	const op = "service.aws.CopyObject"
+
+	_, err := a.Client.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:     aws.String(bucketName),
+		CopySource: aws.String(copySource(bucketName, srcKey)),
+		Key:        aws.String(dstKey),
+	})
+	if err != nil {
+		var noSuchKey *types.NoSuchKey
+		if errors.As(err, &noSuchKey) {
+			return fmt.Errorf("%s: %w", op, service.ErrObjectNotFound)
+		}
+		a.log.Error("couldn't copy object",
+			slog.String("bucket", bucketName),
+			slog.String("src", srcKey),
+			slog.String("dst", dstKey),
+			sl.Err(err),
+		)
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// copySource URL-encodes bucket/key for the x-amz-copy-source header, keeping the slashes.
+func copySource(bucketName string, key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
 	}
-	return err
+	return bucketName + "/" + strings.Join(segments, "/")
 }
@@ -0,0 +1,135 @@
+package backfill
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/maestro-milagro/Post_Service_PB/internal/lib/objkey"
+	"github.com/maestro-milagro/Post_Service_PB/internal/lib/sl"
+	"github.com/maestro-milagro/Post_Service_PB/internal/models"
+	"github.com/maestro-milagro/Post_Service_PB/internal/service"
+	"github.com/maestro-milagro/Post_Service_PB/internal/storage"
+	"log/slog"
+)
+
+type Store interface {
+	LegacyKeyPostsDB(ctx context.Context, prefix string, afterID int64, limit int) ([]models.PostUser, error)
+	UpdatePostKeyDB(ctx context.Context, id int64, oldKey string, newKey string) error
+	CountKeyRefsDB(ctx context.Context, bucket string, key string) (int, error)
+}
+
+type Stats struct {
+	Migrated       int
+	Skipped        int
+	ObjectsDeleted int
+}
+
+// KeyBackfiller moves posts from filename-derived object keys to keys produced by objkey.New.
+// Each post gets its own copy of the object; the old object is removed once no post references it.
+// Posts that shared a filename already point at the same (last uploaded) object, and keep that content.
+type KeyBackfiller struct {
+	log       *slog.Logger
+	store     Store
+	blobs     service.BlobStore
+	batchSize int
+	dryRun    bool
+}
+
+func NewKeyBackfiller(log *slog.Logger, store Store, blobs service.BlobStore, batchSize int, dryRun bool) *KeyBackfiller {
+	return &KeyBackfiller{
+		log:       log.With(slog.String("component", "backfill.KeyBackfiller")),
+		store:     store,
+		blobs:     blobs,
+		batchSize: batchSize,
+		dryRun:    dryRun,
+	}
+}
+
+func (b *KeyBackfiller) Run(ctx context.Context) (Stats, error) {
+	const op = "service.backfill.KeyBackfiller.Run"
+
+	var stats Stats
+	var afterID int64
+
+	for {
+		posts, err := b.store.LegacyKeyPostsDB(ctx, objkey.Prefix, afterID, b.batchSize)
+		if err != nil {
+			return stats, fmt.Errorf("%s: %w", op, err)
+		}
+		if len(posts) == 0 {
+			return stats, nil
+		}
+
+		for _, post := range posts {
+			afterID = post.ID
+
+			migrated, deleted, err := b.migrate(ctx, post)
+			if err != nil {
+				return stats, fmt.Errorf("%s: post %d: %w", op, post.ID, err)
+			}
+			if migrated {
+				stats.Migrated++
+			} else {
+				stats.Skipped++
+			}
+			if deleted {
+				stats.ObjectsDeleted++
+			}
+		}
+	}
+}
+
+func (b *KeyBackfiller) migrate(ctx context.Context, post models.PostUser) (migrated bool, deleted bool, err error) {
+	log := b.log.With(
+		slog.Int64("post_id", post.ID),
+		slog.String("old_key", post.Key),
+	)
+
+	newKey, err := objkey.New(post.Email)
+	if err != nil {
+		return false, false, err
+	}
+
+	if b.dryRun {
+		log.Info("would move post", slog.String("new_key", newKey))
+
+		return true, false, nil
+	}
+
+	if err := b.blobs.CopyObject(ctx, post.Bucket, post.Key, newKey); err != nil {
+		if errors.Is(err, service.ErrObjectNotFound) {
+			log.Warn("object is missing, skipping post")
+
+			return false, false, nil
+		}
+		return false, false, err
+	}
+
+	if err := b.store.UpdatePostKeyDB(ctx, post.ID, post.Key, newKey); err != nil {
+		// Пост удалили или перенесли параллельно: копия никому не нужна
+		if delErr := b.blobs.DeleteObjects(ctx, post.Bucket, []string{newKey}); delErr != nil {
+			log.Error("failed to delete unused copy", slog.String("new_key", newKey), sl.Err(delErr))
+		}
+		if errors.Is(err, storage.ErrPostNotFound) {
+			log.Warn("post changed concurrently, skipping")
+
+			return false, false, nil
+		}
+		return false, false, err
+	}
+
+	log.Info("post moved", slog.String("new_key", newKey))
+
+	refs, err := b.store.CountKeyRefsDB(ctx, post.Bucket, post.Key)
+	if err != nil {
+		return true, false, err
+	}
+	if refs > 0 {
+		return true, false, nil
+	}
+
+	if err := b.blobs.DeleteObjects(ctx, post.Bucket, []string{post.Key}); err != nil {
+		return true, false, err
+	}
+	return true, true, nil
+}
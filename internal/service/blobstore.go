@@ -0,0 +1,30 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+var ErrObjectNotFound = errors.New("object not found")
+
+type ObjectInfo struct {
+	Size         int64
+	ContentType  string
+	ETag         string
+	LastModified time.Time
+}
+
+// BlobStore is the object storage the post contents live in.
+// Implementations: aws.AwsService (any S3-compatible endpoint) and localfs.LocalFS.
+type BlobStore interface {
+	// UploadFile streams body into the store without buffering it as a whole.
+	UploadFile(ctx context.Context, bucketName string, key string, body io.Reader, contentType string) error
+	StatObject(ctx context.Context, bucketName string, key string) (ObjectInfo, error)
+	// OpenObject returns length bytes starting at offset; a negative length reads to the end.
+	OpenObject(ctx context.Context, bucketName string, key string, offset int64, length int64) (io.ReadCloser, error)
+	DeleteObjects(ctx context.Context, bucketName string, objectKeys []string) error
+	// CopyObject duplicates an object inside the store without passing it through the service.
+	CopyObject(ctx context.Context, bucketName string, srcKey string, dstKey string) error
+}
@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/maestro-milagro/Post_Service_PB/internal/models"
+	"github.com/maestro-milagro/Post_Service_PB/internal/service/envelope"
+	"io"
+)
+
+var ErrPostTooLarge = errors.New("post too large to read at once")
+
+// Content is the BlobStore as seen by the post handlers: post bodies are sealed
+// with envelope encryption on upload when the keyring has an active master key,
+// and unsealed on read according to the key id stored with the post. The wrapped
+// data key lives in the post row, which the BlobStore backends never see, so
+// encryption cannot sit behind the BlobStore interface itself. Content therefore
+// exposes no raw reads: every read of a body goes through OpenPost, and the
+// components given the bare BlobStore (delete, reaper, key backfill) only copy
+// or delete objects, which works on sealed bytes as is.
+type Content struct {
+	store BlobStore
+	keys  *envelope.Keyring
+}
+
+func NewContent(store BlobStore, keys *envelope.Keyring) *Content {
+	return &Content{store: store, keys: keys}
+}
+
+// Sealing is what has to be stored with the post to read its body back;
+// both fields are empty for bodies stored in plaintext.
+type Sealing struct {
+	KeyID   string
+	DataKey []byte
+}
+
+// UploadPost stores body under key, encrypting it when encryption is enabled.
+func (c *Content) UploadPost(ctx context.Context, bucketName string, key string, body io.Reader, contentType string) (Sealing, error) {
+	const op = "service.Content.UploadPost"
+
+	if !c.keys.Enabled() {
+		return Sealing{}, c.store.UploadFile(ctx, bucketName, key, body, contentType)
+	}
+
+	dataKey, err := c.keys.NewDataKey()
+	if err != nil {
+		return Sealing{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	sealed, err := envelope.Encrypt(dataKey.Plain, body)
+	if err != nil {
+		return Sealing{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Тип содержимого объекта не раскрываем, он хранится в посте
+	if err := c.store.UploadFile(ctx, bucketName, key, sealed, "application/octet-stream"); err != nil {
+		return Sealing{}, err
+	}
+
+	return Sealing{KeyID: dataKey.KeyID, DataKey: dataKey.Wrapped}, nil
+}
+
+// StatPost describes the post body as its readers see it. The post metadata wins
+// over the object's: localfs keeps no content type, and a sealed object differs
+// from the plaintext in size and type.
+func (c *Content) StatPost(ctx context.Context, bucketName string, post models.PostUser) (ObjectInfo, error) {
+	info, err := c.store.StatObject(ctx, bucketName, post.Key)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+
+	if post.ContentType != "" {
+		info.ContentType = post.ContentType
+	}
+	if post.Encrypted() {
+		info.Size = post.Size
+	}
+	if post.Checksum != "" {
+		info.ETag = `"` + post.Checksum + `"`
+	}
+	return info, nil
+}
+
+// CopyObject duplicates the stored object; a sealed body stays sealed under the same data key.
+func (c *Content) CopyObject(ctx context.Context, bucketName string, srcKey string, dstKey string) error {
+	return c.store.CopyObject(ctx, bucketName, srcKey, dstKey)
+}
+
+func (c *Content) DeleteObjects(ctx context.Context, bucketName string, objectKeys []string) error {
+	return c.store.DeleteObjects(ctx, bucketName, objectKeys)
+}
+
+// OpenPost returns length bytes of the post body starting at offset; a negative length reads to the end.
+func (c *Content) OpenPost(ctx context.Context, bucketName string, post models.PostUser, offset int64, length int64) (io.ReadCloser, error) {
+	const op = "service.Content.OpenPost"
+
+	if !post.Encrypted() {
+		return c.store.OpenObject(ctx, bucketName, post.Key, offset, length)
+	}
+
+	dataKey, err := c.keys.Unwrap(post.EncKeyID, post.EncDataKey)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if length < 0 || offset+length > post.Size {
+		length = max(post.Size-offset, 0)
+	}
+
+	chunk, start, sealedLength, skip := envelope.SealedRange(post.Size, offset, length)
+
+	body, err := c.store.OpenObject(ctx, bucketName, post.Key, start, sealedLength)
+	if err != nil {
+		return nil, err
+	}
+
+	plain, err := envelope.Decrypt(dataKey, body, chunk)
+	if err != nil {
+		body.Close()
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := io.CopyN(io.Discard, plain, skip); err != nil {
+		body.Close()
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return readCloser{Reader: io.LimitReader(plain, length), Closer: body}, nil
+}
+
+// ReadPost returns the whole post body, or ErrPostTooLarge when it is longer
+// than maxSize. Larger bodies have to be streamed with OpenPost.
+func (c *Content) ReadPost(ctx context.Context, bucketName string, post models.PostUser, maxSize int64) ([]byte, error) {
+	if post.Size > maxSize {
+		return nil, ErrPostTooLarge
+	}
+
+	body, err := c.OpenPost(ctx, bucketName, post, 0, -1)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	// Размер в записи поста мог разойтись с объектом, поэтому читаем не больше лимита
+	data, err := io.ReadAll(io.LimitReader(body, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxSize {
+		return nil, ErrPostTooLarge
+	}
+	return data, nil
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
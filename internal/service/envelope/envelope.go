@@ -0,0 +1,153 @@
+// Package envelope implements envelope encryption of post bodies: every object is
+// encrypted with its own random data key, and only the data key, wrapped by a master
+// key from the config, is stored with the post. Rotating a master key therefore means
+// rewrapping data keys in the database, not re-encrypting objects.
+package envelope
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+const keySize = 32
+
+var (
+	ErrUnknownKey = errors.New("unknown master key")
+	ErrNoActive   = errors.New("no active master key")
+	ErrBadWrapped = errors.New("malformed wrapped data key")
+)
+
+// DataKey is a fresh object key: Plain encrypts the object, Wrapped and KeyID are stored with the post.
+type DataKey struct {
+	KeyID   string
+	Plain   []byte
+	Wrapped []byte
+}
+
+// Keyring holds the master keys. New data keys are wrapped with the active one,
+// the others are kept to unwrap data keys that have not been rotated yet.
+type Keyring struct {
+	active string
+	keys   map[string]cipher.AEAD
+}
+
+// NewKeyring parses base64-encoded 256-bit master keys. An empty active id
+// gives a keyring that only unwraps, which disables encryption of new posts.
+func NewKeyring(active string, masterKeys map[string]string) (*Keyring, error) {
+	const op = "envelope.NewKeyring"
+
+	k := &Keyring{
+		active: active,
+		keys:   make(map[string]cipher.AEAD, len(masterKeys)),
+	}
+
+	for id, encoded := range masterKeys {
+		raw, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("%s: key %q: %w", op, id, err)
+		}
+		if len(raw) != keySize {
+			return nil, fmt.Errorf("%s: key %q must be %d bytes, got %d", op, id, keySize, len(raw))
+		}
+		aead, err := newGCM(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s: key %q: %w", op, id, err)
+		}
+		k.keys[id] = aead
+	}
+
+	if active != "" {
+		if _, ok := k.keys[active]; !ok {
+			return nil, fmt.Errorf("%s: active key %q: %w", op, active, ErrUnknownKey)
+		}
+	}
+
+	return k, nil
+}
+
+// Enabled reports whether new posts are encrypted.
+func (k *Keyring) Enabled() bool {
+	return k != nil && k.active != ""
+}
+
+func (k *Keyring) Active() string {
+	return k.active
+}
+
+func (k *Keyring) NewDataKey() (DataKey, error) {
+	if !k.Enabled() {
+		return DataKey{}, ErrNoActive
+	}
+
+	plain := make([]byte, keySize)
+	if _, err := rand.Read(plain); err != nil {
+		return DataKey{}, err
+	}
+
+	wrapped, err := k.wrap(k.active, plain)
+	if err != nil {
+		return DataKey{}, err
+	}
+
+	return DataKey{KeyID: k.active, Plain: plain, Wrapped: wrapped}, nil
+}
+
+func (k *Keyring) Unwrap(keyID string, wrapped []byte) ([]byte, error) {
+	aead, ok := k.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, keyID)
+	}
+	if len(wrapped) < aead.NonceSize() {
+		return nil, ErrBadWrapped
+	}
+
+	nonce, sealed := wrapped[:aead.NonceSize()], wrapped[aead.NonceSize():]
+
+	// Id ключа входит в AAD: обёрнутый ключ нельзя выдать за обёрнутый другим ключом
+	plain, err := aead.Open(nil, nonce, sealed, []byte(keyID))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrBadWrapped, err)
+	}
+	return plain, nil
+}
+
+// Rewrap moves a data key under the active master key.
+func (k *Keyring) Rewrap(keyID string, wrapped []byte) (string, []byte, error) {
+	if !k.Enabled() {
+		return "", nil, ErrNoActive
+	}
+
+	plain, err := k.Unwrap(keyID, wrapped)
+	if err != nil {
+		return "", nil, err
+	}
+
+	rewrapped, err := k.wrap(k.active, plain)
+	if err != nil {
+		return "", nil, err
+	}
+	return k.active, rewrapped, nil
+}
+
+func (k *Keyring) wrap(keyID string, plain []byte) ([]byte, error) {
+	aead := k.keys[keyID]
+
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plain)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, plain, []byte(keyID)), nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,147 @@
+package envelope
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// Objects are sealed in chunks of ChunkSize plaintext bytes so that they can be
+// streamed and read from an arbitrary offset. The last chunk is always shorter
+// than ChunkSize, possibly empty, and is sealed with a final flag in its nonce,
+// which makes truncation of the object detectable.
+const (
+	ChunkSize = 64 << 10
+	overhead  = 16
+	sealedLen = ChunkSize + overhead
+)
+
+var ErrTruncated = errors.New("encrypted object is truncated")
+
+// SealedSize is the size of the stored object for size bytes of plaintext.
+func SealedSize(size int64) int64 {
+	return (size/ChunkSize+1)*overhead + size
+}
+
+// SealedRange maps length plaintext bytes at offset of an object of the given size
+// to the stored bytes that have to be read. The chunk containing offset starts the
+// range, and skip plaintext bytes of it precede offset.
+func SealedRange(size, offset, length int64) (chunk, start, sealedLength, skip int64) {
+	chunk = offset / ChunkSize
+	last := (offset + length) / ChunkSize
+
+	start = chunk * sealedLen
+	end := min((last+1)*sealedLen, SealedSize(size))
+
+	return chunk, start, end - start, offset - chunk*ChunkSize
+}
+
+func nonce(index int64, final bool) []byte {
+	n := make([]byte, 12)
+	binary.BigEndian.PutUint64(n, uint64(index))
+	if final {
+		n[11] = 1
+	}
+	return n
+}
+
+type encrypter struct {
+	aead  cipher.AEAD
+	src   io.Reader
+	index int64
+	buf   []byte
+	// sealed keeps the allocation behind out
+	sealed []byte
+	out    []byte
+	done   bool
+	err    error
+}
+
+// Encrypt returns a reader of src sealed with the data key.
+func Encrypt(dataKey []byte, src io.Reader) (io.Reader, error) {
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return &encrypter{aead: aead, src: src, buf: make([]byte, ChunkSize)}, nil
+}
+
+func (e *encrypter) Read(p []byte) (int, error) {
+	for len(e.out) == 0 {
+		if e.err != nil {
+			return 0, e.err
+		}
+		if e.done {
+			return 0, io.EOF
+		}
+
+		n, err := io.ReadFull(e.src, e.buf)
+		switch {
+		case err == nil:
+		case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+			e.done = true
+		default:
+			e.err = err
+			return 0, err
+		}
+
+		e.sealed = e.aead.Seal(e.sealed[:0], nonce(e.index, e.done), e.buf[:n], nil)
+		e.out = e.sealed
+		e.index++
+	}
+
+	n := copy(p, e.out)
+	e.out = e.out[n:]
+	return n, nil
+}
+
+type decrypter struct {
+	aead  cipher.AEAD
+	src   io.Reader
+	index int64
+	buf   []byte
+	out   []byte
+	done  bool
+}
+
+// Decrypt returns a reader of the plaintext of src, whose first chunk has the given index.
+// A chunk that fails authentication, or a missing final chunk, is reported as an error.
+func Decrypt(dataKey []byte, src io.Reader, chunk int64) (io.Reader, error) {
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return &decrypter{aead: aead, src: src, index: chunk, buf: make([]byte, sealedLen)}, nil
+}
+
+func (d *decrypter) Read(p []byte) (int, error) {
+	for len(d.out) == 0 {
+		if d.done {
+			return 0, io.EOF
+		}
+
+		n, err := io.ReadFull(d.src, d.buf)
+		switch {
+		case err == nil:
+		case errors.Is(err, io.ErrUnexpectedEOF):
+			d.done = true
+		case errors.Is(err, io.EOF):
+			// Чанк с флагом final всегда есть, пустой поток означает обрезанный объект
+			return 0, ErrTruncated
+		default:
+			return 0, err
+		}
+
+		plain, err := d.aead.Open(d.buf[:0], nonce(d.index, d.done), d.buf[:n], nil)
+		if err != nil {
+			return 0, err
+		}
+		d.out = plain
+		d.index++
+	}
+
+	n := copy(p, d.out)
+	d.out = d.out[n:]
+	return n, nil
+}
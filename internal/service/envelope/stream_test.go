@@ -0,0 +1,178 @@
+package envelope
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+)
+
+var sizes = []int64{0, 1, ChunkSize - 1, ChunkSize, ChunkSize + 1, 2*ChunkSize + 7}
+
+func testKey(t *testing.T) []byte {
+	t.Helper()
+
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func seal(t *testing.T, key []byte, size int64) (plain, sealed []byte) {
+	t.Helper()
+
+	plain = make([]byte, size)
+	if _, err := rand.Read(plain); err != nil {
+		t.Fatal(err)
+	}
+
+	enc, err := Encrypt(key, bytes.NewReader(plain))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err = io.ReadAll(enc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return plain, sealed
+}
+
+func open(key []byte, sealed []byte, chunk int64) ([]byte, error) {
+	dec, err := Decrypt(key, bytes.NewReader(sealed), chunk)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(dec)
+}
+
+func TestRoundTrip(t *testing.T) {
+	key := testKey(t)
+
+	for _, size := range sizes {
+		t.Run(fmt.Sprint(size), func(t *testing.T) {
+			plain, sealed := seal(t, key, size)
+
+			if int64(len(sealed)) != SealedSize(size) {
+				t.Errorf("sealed %d bytes, SealedSize says %d", len(sealed), SealedSize(size))
+			}
+
+			got, err := open(key, sealed, 0)
+			if err != nil {
+				t.Fatalf("Decrypt: %v", err)
+			}
+			if !bytes.Equal(got, plain) {
+				t.Error("decrypted plaintext differs")
+			}
+		})
+	}
+}
+
+func TestDecryptRejectsTampering(t *testing.T) {
+	key := testKey(t)
+	_, sealed := seal(t, key, 2*ChunkSize+7)
+
+	chunk := func(i int) []byte {
+		return sealed[i*sealedLen : min((i+1)*sealedLen, len(sealed))]
+	}
+	join := func(parts ...[]byte) []byte {
+		return bytes.Join(parts, nil)
+	}
+
+	tests := []struct {
+		name   string
+		sealed []byte
+		// key, когда задан, заменяет ключ, которым объект запечатан
+		key  []byte
+		want error
+	}{
+		{"empty", nil, nil, ErrTruncated},
+		// Обрезка по границе чанка: последнего чанка с флагом final нет
+		{"final chunk dropped", sealed[:2*sealedLen], nil, ErrTruncated},
+		{"cut inside a chunk", sealed[:sealedLen+100], nil, nil},
+		{"chunks swapped", join(chunk(1), chunk(0), chunk(2)), nil, nil},
+		{"chunk repeated", join(chunk(0), chunk(0), chunk(2)), nil, nil},
+		{"middle chunk dropped", join(chunk(0), chunk(2)), nil, nil},
+		{"byte flipped", func() []byte {
+			b := bytes.Clone(sealed)
+			b[ChunkSize/2] ^= 1
+			return b
+		}(), nil, nil},
+		{"wrong key", sealed, testKey(t), nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := key
+			if tt.key != nil {
+				k = tt.key
+			}
+
+			_, err := open(k, tt.sealed, 0)
+			if err == nil {
+				t.Fatal("tampered object decrypted without error")
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	// Чанк, прочитанный не со своим индексом, не проходит проверку
+	if _, err := open(key, chunk(1), 0); err == nil {
+		t.Error("chunk 1 decrypted as chunk 0")
+	}
+}
+
+func TestSealedRange(t *testing.T) {
+	key := testKey(t)
+
+	for _, size := range sizes {
+		plain, sealed := seal(t, key, size)
+
+		ranges := [][2]int64{
+			{0, size},
+			{0, 0},
+			{0, min(size, 1)},
+			{size / 2, size - size/2},
+			{size, 0},
+		}
+		if size > ChunkSize {
+			ranges = append(ranges,
+				[2]int64{ChunkSize - 1, 2},
+				[2]int64{ChunkSize, 1},
+				[2]int64{ChunkSize, size - ChunkSize},
+				[2]int64{1, ChunkSize},
+			)
+		}
+
+		for _, rng := range ranges {
+			offset, length := rng[0], rng[1]
+
+			t.Run(fmt.Sprintf("%d/%d+%d", size, offset, length), func(t *testing.T) {
+				chunk, start, sealedLength, skip := SealedRange(size, offset, length)
+				if start < 0 || start+sealedLength > int64(len(sealed)) {
+					t.Fatalf("range [%d, %d) outside of %d sealed bytes", start, start+sealedLength, len(sealed))
+				}
+
+				// Так же, как Content.OpenPost: дешифруем с нужного чанка, пропускаем skip и читаем length байт
+				dec, err := Decrypt(key, bytes.NewReader(sealed[start:start+sealedLength]), chunk)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if _, err := io.CopyN(io.Discard, dec, skip); err != nil {
+					t.Fatalf("skip: %v", err)
+				}
+				got, err := io.ReadAll(io.LimitReader(dec, length))
+				if err != nil {
+					t.Fatalf("read: %v", err)
+				}
+				if !bytes.Equal(got, plain[offset:offset+length]) {
+					t.Errorf("got %d bytes that differ from the plaintext slice", len(got))
+				}
+			})
+		}
+	}
+}
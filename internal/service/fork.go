@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/maestro-milagro/Post_Service_PB/internal/lib/sl"
+	"github.com/maestro-milagro/Post_Service_PB/internal/models"
+	"log/slog"
+	"slices"
+)
+
+var (
+	ErrNotForkable    = errors.New("burn-after-read posts cannot be forked")
+	ErrForkTooVisible = errors.New("fork cannot be visible more widely than its source")
+)
+
+// maxLineageDepth bounds how far back the fork lineage of a post is shown.
+const maxLineageDepth = 20
+
+type DBForks interface {
+	AncestorsDB(ctx context.Context, id int64, depth int) ([]models.PostUser, error)
+	ForkCountDB(ctx context.Context, id int64) (int, error)
+}
+
+// Forkable reports whether source, already checked to be visible to the caller, can be forked.
+func Forkable(source models.PostUser) error {
+	// Копия позволила бы прочитать одноразовый пост больше одного раза
+	if source.BurnAfterRead {
+		return ErrNotForkable
+	}
+	return nil
+}
+
+// ForkVisibility resolves the visibility of a fork of source made by email. An
+// empty requested keeps that of the source where allowed, private otherwise. A fork is never visible more
+// widely than its source. The followers of another user are a different
+// audience, so their followers-only posts may only be forked as private.
+func ForkVisibility(source models.PostUser, email string, requested string) (string, error) {
+	var allowed []string
+	switch source.Visibility {
+	case models.VisibilityPublic:
+		allowed = []string{models.VisibilityPublic, models.VisibilityUnlisted, models.VisibilityFollowers, models.VisibilityPrivate}
+	case models.VisibilityUnlisted:
+		allowed = []string{models.VisibilityUnlisted, models.VisibilityPrivate}
+	case models.VisibilityFollowers:
+		if source.Email == email {
+			allowed = []string{models.VisibilityFollowers, models.VisibilityPrivate}
+		} else {
+			allowed = []string{models.VisibilityPrivate}
+		}
+	default:
+		allowed = []string{models.VisibilityPrivate}
+	}
+
+	if requested == "" {
+		return allowed[0], nil
+	}
+	if !slices.Contains(allowed, requested) {
+		return "", ErrForkTooVisible
+	}
+	return requested, nil
+}
+
+// Fork stores a copy of source owned by email. The body must already be copied
+// to key; server-side encrypted copies keep the data key of the source. The copy
+// keeps the expiry and the download password of the source as well.
+func (s *Service) Fork(ctx context.Context, email string, source models.PostUser, key string, visibility string) (int64, string, error) {
+	const op = "service.Fork"
+
+	if err := Forkable(source); err != nil {
+		return 0, "", fmt.Errorf("%s: %w", op, err)
+	}
+	visibility, err := ForkVisibility(source, email, visibility)
+	if err != nil {
+		return 0, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	parentID := source.ID
+
+	id, slug, err := s.SavePost(ctx, models.PostUser{
+		Email:           email,
+		Bucket:          source.Bucket,
+		Key:             key,
+		Title:           source.Title,
+		FileName:        source.FileName,
+		ContentType:     source.ContentType,
+		Size:            source.Size,
+		Checksum:        source.Checksum,
+		ExpiresAt:       source.ExpiresAt,
+		Visibility:      visibility,
+		PasswordHash:    source.PasswordHash,
+		EncKeyID:        source.EncKeyID,
+		EncDataKey:      source.EncDataKey,
+		ClientEncrypted: source.ClientEncrypted,
+		ParentID:        &parentID,
+		Syntax:          source.Syntax,
+	})
+	if err != nil {
+		return 0, "", fmt.Errorf("%s: %w", op, err)
+	}
+	return id, slug, nil
+}
+
+// Lineage returns the fork lineage of post as seen by viewer.
+func (s *Service) Lineage(ctx context.Context, viewer string, post models.PostUser) (models.Lineage, error) {
+	const op = "service.Lineage"
+
+	log := s.log.With(
+		slog.String("op", op),
+		slog.Int64("post_id", post.ID),
+	)
+
+	lineage := models.Lineage{Ancestors: []models.PostRef{}}
+
+	forks, err := s.dbForks.ForkCountDB(ctx, post.ID)
+	if err != nil {
+		log.Error("error while counting forks", sl.Err(err))
+
+		return models.Lineage{}, fmt.Errorf("%s: %w", op, err)
+	}
+	lineage.Forks = forks
+
+	if post.ParentID == nil {
+		return lineage, nil
+	}
+
+	ancestors, err := s.dbForks.AncestorsDB(ctx, post.ID, maxLineageDepth)
+	if err != nil {
+		log.Error("error while getting ancestors", sl.Err(err))
+
+		return models.Lineage{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, ancestor := range ancestors {
+		visible, err := s.canView(ctx, viewer, ancestor)
+		if err != nil {
+			return models.Lineage{}, fmt.Errorf("%s: %w", op, err)
+		}
+		// Скрытый предок обрывает цепочку, чтобы не раскрывать ни его, ни то, что было до него
+		if !visible {
+			break
+		}
+		lineage.Ancestors = append(lineage.Ancestors, ancestor.Ref())
+	}
+
+	return lineage, nil
+}
@@ -0,0 +1,42 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/maestro-milagro/Post_Service_PB/internal/models"
+)
+
+func TestForkVisibility(t *testing.T) {
+	const author, reader = "author@example.com", "reader@example.com"
+
+	tests := []struct {
+		source    string
+		email     string
+		requested string
+		want      string
+		err       error
+	}{
+		{models.VisibilityPublic, reader, "", models.VisibilityPublic, nil},
+		{models.VisibilityPublic, reader, models.VisibilityFollowers, models.VisibilityFollowers, nil},
+		{models.VisibilityUnlisted, reader, "", models.VisibilityUnlisted, nil},
+		{models.VisibilityUnlisted, reader, models.VisibilityPublic, "", ErrForkTooVisible},
+		{models.VisibilityUnlisted, reader, models.VisibilityFollowers, "", ErrForkTooVisible},
+		{models.VisibilityFollowers, reader, "", models.VisibilityPrivate, nil},
+		{models.VisibilityFollowers, reader, models.VisibilityFollowers, "", ErrForkTooVisible},
+		{models.VisibilityFollowers, reader, models.VisibilityPublic, "", ErrForkTooVisible},
+		{models.VisibilityFollowers, author, "", models.VisibilityFollowers, nil},
+		{models.VisibilityPrivate, author, "", models.VisibilityPrivate, nil},
+		{models.VisibilityPrivate, author, models.VisibilityUnlisted, "", ErrForkTooVisible},
+	}
+
+	for _, tt := range tests {
+		source := models.PostUser{Email: author, Visibility: tt.source}
+
+		got, err := ForkVisibility(source, tt.email, tt.requested)
+		if !errors.Is(err, tt.err) || got != tt.want {
+			t.Errorf("ForkVisibility(%s by %s, %q) = %q, %v; want %q, %v",
+				tt.source, tt.email, tt.requested, got, err, tt.want, tt.err)
+		}
+	}
+}
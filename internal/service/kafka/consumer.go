@@ -0,0 +1,138 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/IBM/sarama"
+	"github.com/maestro-milagro/Post_Service_PB/internal/lib/sl"
+	"github.com/maestro-milagro/Post_Service_PB/internal/models"
+	"log/slog"
+	"time"
+)
+
+const (
+	minRetryDelay = 500 * time.Millisecond
+	maxRetryDelay = 30 * time.Second
+)
+
+type PostHandler interface {
+	FanOut(ctx context.Context, post models.Post) error
+}
+
+// Consumer reads post-created events as part of a consumer group and hands
+// them to PostHandler. An offset is committed only after the handler succeeded.
+type Consumer struct {
+	log     *slog.Logger
+	group   sarama.ConsumerGroup
+	topics  []string
+	handler PostHandler
+}
+
+func NewConsumer(log *slog.Logger, brokers []string, groupID string, topics []string, handler PostHandler) (*Consumer, error) {
+	const op = "service.kafka.NewConsumer"
+
+	config := sarama.NewConfig()
+	config.Consumer.Offsets.Initial = sarama.OffsetOldest
+	config.Consumer.Return.Errors = true
+
+	group, err := sarama.NewConsumerGroup(brokers, groupID, config)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &Consumer{
+		log:     log.With(slog.String("group", groupID)),
+		group:   group,
+		topics:  topics,
+		handler: handler,
+	}, nil
+}
+
+// Run consumes until ctx is cancelled; Consume returns on every rebalance, so it is called in a loop.
+func (c *Consumer) Run(ctx context.Context) error {
+	const op = "service.kafka.Consumer.Run"
+
+	go func() {
+		for err := range c.group.Errors() {
+			c.log.Error("consumer group error", sl.Err(err))
+		}
+	}()
+
+	for {
+		if err := c.group.Consume(ctx, c.topics, c); err != nil {
+			if errors.Is(err, sarama.ErrClosedConsumerGroup) {
+				return nil
+			}
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+	}
+}
+
+func (c *Consumer) Close() error {
+	return c.group.Close()
+}
+
+func (c *Consumer) Setup(sarama.ConsumerGroupSession) error { return nil }
+
+func (c *Consumer) Cleanup(sarama.ConsumerGroupSession) error { return nil }
+
+func (c *Consumer) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	for {
+		select {
+		case msg, ok := <-claim.Messages():
+			if !ok {
+				return nil
+			}
+			if !c.handle(session.Context(), msg) {
+				// Сессия закончилась до успешной обработки: сообщение придёт снова после ребаланса
+				return nil
+			}
+			session.MarkMessage(msg, "")
+		case <-session.Context().Done():
+			return nil
+		}
+	}
+}
+
+// handle retries the handler with backoff, keeping the partition blocked so events
+// stay in order. It reports false only when ctx ended before the message was handled.
+func (c *Consumer) handle(ctx context.Context, msg *sarama.ConsumerMessage) bool {
+	log := c.log.With(
+		slog.String("topic", msg.Topic),
+		slog.Int("partition", int(msg.Partition)),
+		slog.Int64("offset", msg.Offset),
+	)
+
+	var post models.Post
+	if err := json.Unmarshal(msg.Value, &post); err != nil {
+		// Битое сообщение не исправится повторной попыткой, пропускаем его
+		log.Error("skipping malformed message", sl.Err(err))
+
+		return true
+	}
+
+	delay := minRetryDelay
+	for {
+		err := c.handler.FanOut(ctx, post)
+		if err == nil {
+			return true
+		}
+		log.Warn("failed to handle post event, retrying", slog.Duration("delay", delay), sl.Err(err))
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(delay):
+		}
+
+		delay *= 2
+		if delay > maxRetryDelay {
+			delay = maxRetryDelay
+		}
+	}
+}
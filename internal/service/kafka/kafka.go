@@ -1,63 +1,184 @@
 package kafka
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/IBM/sarama"
+	"github.com/maestro-milagro/Post_Service_PB/internal/lib/sl"
 	"github.com/maestro-milagro/Post_Service_PB/internal/models"
-	"log"
 	"log/slog"
+	"sync"
 )
 
+var ErrProducerClosed = errors.New("producer is closed")
+
+// KafkaProducer wraps a sarama.AsyncProducer with a single delivery-report loop.
+// Messages are keyed by the author's email, so the hash partitioner keeps
+// every author's events in one partition and therefore in order.
 type KafkaProducer struct {
 	log      *slog.Logger
 	producer sarama.AsyncProducer
+
+	// mu guards closed: sending to Input after AsyncClose panics
+	mu     sync.RWMutex
+	closed bool
+
+	loopDone chan struct{}
 }
 
-func New(log *slog.Logger, brokers []string) *KafkaProducer {
+func New(log *slog.Logger, brokers []string) (*KafkaProducer, error) {
+	const op = "service.kafka.New"
+
 	config := sarama.NewConfig()
-	config.Producer.Partitioner = sarama.NewRandomPartitioner
+	config.Producer.Partitioner = sarama.NewHashPartitioner
 	config.Producer.RequiredAcks = sarama.WaitForAll
 	config.Producer.Return.Successes = true
+	config.Producer.Return.Errors = true
+
 	producer, err := sarama.NewAsyncProducer(brokers, config)
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
-	return &KafkaProducer{
+
+	kf := &KafkaProducer{
 		log:      log,
 		producer: producer,
+		loopDone: make(chan struct{}),
 	}
+	go kf.deliveryLoop()
+
+	return kf, nil
 }
 
-func prepareMessage(topic string, message []byte) *sarama.ProducerMessage {
+func prepareMessage(topic string, key string, message []byte) *sarama.ProducerMessage {
 	msg := &sarama.ProducerMessage{
-		Topic:     topic,
-		Partition: -1,
-		Value:     sarama.ByteEncoder(message),
+		Topic: topic,
+		Key:   sarama.StringEncoder(key),
+		Value: sarama.ByteEncoder(message),
 	}
 	return msg
 }
 
-func (kf *KafkaProducer) Produce(post models.Post, topic string) {
-	go func() {
-		for err := range kf.producer.Errors() {
-			kf.log.Error("async producer error:", err.Err)
-		}
-	}()
+// deliveryLoop is the only reader of Successes and Errors; it runs until
+// the producer is closed and both channels are drained.
+func (kf *KafkaProducer) deliveryLoop() {
+	defer close(kf.loopDone)
 
-	go func() {
-		for succ := range kf.producer.Successes() {
-			kf.log.Info("async producer success:", succ)
+	successes := kf.producer.Successes()
+	errs := kf.producer.Errors()
+
+	for successes != nil || errs != nil {
+		select {
+		case msg, ok := <-successes:
+			if !ok {
+				successes = nil
+				continue
+			}
+			kf.log.Debug("message delivered",
+				slog.String("topic", msg.Topic),
+				slog.Int("partition", int(msg.Partition)),
+				slog.Int64("offset", msg.Offset),
+			)
+			report(msg, nil)
+		case perr, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			kf.log.Error("failed to deliver message", slog.String("topic", perr.Msg.Topic), sl.Err(perr.Err))
+			report(perr.Msg, perr.Err)
 		}
-	}()
+	}
+}
+
+// report hands the delivery result to a ProduceSync caller waiting on the message.
+func report(msg *sarama.ProducerMessage, err error) {
+	if done, ok := msg.Metadata.(chan error); ok {
+		done <- err
+	}
+}
+
+// Produce enqueues the post without waiting for the broker; failures are only logged.
+func (kf *KafkaProducer) Produce(post models.Post, topic string) error {
+	const op = "service.kafka.Produce"
+
+	postJson, err := json.Marshal(post)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := kf.send(context.Background(), prepareMessage(topic, post.Email, postJson)); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	kf.log.Info("post sent", slog.Int("post_id", post.PostID))
+
+	return nil
+}
+
+// ProduceSync blocks until the broker acknowledges the message, so the caller can retry on failure.
+func (kf *KafkaProducer) ProduceSync(ctx context.Context, post models.Post, topic string) error {
+	const op = "service.kafka.ProduceSync"
 
 	postJson, err := json.Marshal(post)
 	if err != nil {
-		log.Fatal(err)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	msg := prepareMessage(topic, post.Email, postJson)
+	// Буфер на одно значение: цикл доставки не должен блокироваться, если вызывающий уже ушёл по ctx
+	done := make(chan error, 1)
+	msg.Metadata = done
+
+	if err := kf.send(ctx, msg); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("%s: %w", op, ctx.Err())
+	}
+}
+
+func (kf *KafkaProducer) send(ctx context.Context, msg *sarama.ProducerMessage) error {
+	kf.mu.RLock()
+	defer kf.mu.RUnlock()
+
+	if kf.closed {
+		return ErrProducerClosed
 	}
 
-	msg := prepareMessage(topic, postJson)
+	select {
+	case kf.producer.Input() <- msg:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
 
-	kf.producer.Input() <- msg
+// Close stops accepting messages and waits until the buffered ones are flushed
+// and their delivery reports processed, or ctx expires.
+func (kf *KafkaProducer) Close(ctx context.Context) error {
+	const op = "service.kafka.Close"
 
-	kf.log.Info("post sent %v: ", post.PostID)
+	kf.mu.Lock()
+	if !kf.closed {
+		kf.closed = true
+		kf.producer.AsyncClose()
+	}
+	kf.mu.Unlock()
+
+	select {
+	case <-kf.loopDone:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("%s: %w", op, ctx.Err())
+	}
 }
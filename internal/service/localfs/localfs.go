@@ -0,0 +1,175 @@
+package localfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/maestro-milagro/Post_Service_PB/internal/lib/sl"
+	"github.com/maestro-milagro/Post_Service_PB/internal/service"
+	"io"
+	"io/fs"
+	"log/slog"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var ErrInvalidKey = errors.New("invalid object key")
+
+// LocalFS keeps objects on disk as <root>/<bucket>/<key>.
+// It is meant for development and integration tests, not for production.
+type LocalFS struct {
+	log  *slog.Logger
+	root string
+}
+
+func New(log *slog.Logger, root string) (*LocalFS, error) {
+	const op = "service.localfs.New"
+
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &LocalFS{
+		log:  log,
+		root: root,
+	}, nil
+}
+
+func (l *LocalFS) UploadFile(ctx context.Context, bucketName string, key string, body io.Reader, contentType string) error {
+	const op = "service.localfs.UploadFile"
+
+	path, err := l.path(bucketName, key)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Пишем во временный файл и переименовываем, чтобы читатели не увидели частично записанный объект
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, body); err != nil {
+		tmp.Close()
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (l *LocalFS) StatObject(ctx context.Context, bucketName string, key string) (service.ObjectInfo, error) {
+	const op = "service.localfs.StatObject"
+
+	path, err := l.path(bucketName, key)
+	if err != nil {
+		return service.ObjectInfo{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return service.ObjectInfo{}, fmt.Errorf("%s: %w", op, service.ErrObjectNotFound)
+		}
+		return service.ObjectInfo{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Метаданные на диске не храним: тип берём из расширения, ETag из размера и времени изменения
+	return service.ObjectInfo{
+		Size:         fi.Size(),
+		ContentType:  mime.TypeByExtension(filepath.Ext(key)),
+		ETag:         fmt.Sprintf(`"%x-%x"`, fi.ModTime().UnixNano(), fi.Size()),
+		LastModified: fi.ModTime(),
+	}, nil
+}
+
+func (l *LocalFS) OpenObject(ctx context.Context, bucketName string, key string, offset int64, length int64) (io.ReadCloser, error) {
+	const op = "service.localfs.OpenObject"
+
+	path, err := l.path(bucketName, key)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("%s: %w", op, service.ErrObjectNotFound)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if length < 0 {
+		if _, err := f.Seek(offset, io.SeekStart); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		return f, nil
+	}
+
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.NewSectionReader(f, offset, length), f}, nil
+}
+
+func (l *LocalFS) DeleteObjects(ctx context.Context, bucketName string, objectKeys []string) error {
+	const op = "service.localfs.DeleteObjects"
+
+	for _, key := range objectKeys {
+		path, err := l.path(bucketName, key)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		// Как и в S3, удаление отсутствующего объекта не считается ошибкой
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			l.log.Error("couldn't delete object", slog.String("key", key), sl.Err(err))
+
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	return nil
+}
+
+func (l *LocalFS) CopyObject(ctx context.Context, bucketName string, srcKey string, dstKey string) error {
+	const op = "service.localfs.CopyObject"
+
+	src, err := l.OpenObject(ctx, bucketName, srcKey, 0, -1)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer src.Close()
+
+	if err := l.UploadFile(ctx, bucketName, dstKey, src, ""); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// path maps bucket and key onto the filesystem, refusing keys that escape the bucket directory.
+func (l *LocalFS) path(bucketName string, key string) (string, error) {
+	if bucketName == "" || strings.ContainsAny(bucketName, `/\`) || bucketName == "." || bucketName == ".." {
+		return "", ErrInvalidKey
+	}
+
+	bucketDir := filepath.Join(l.root, bucketName)
+	path := filepath.Join(bucketDir, filepath.FromSlash(key))
+
+	if key == "" || !strings.HasPrefix(path, bucketDir+string(filepath.Separator)) {
+		return "", ErrInvalidKey
+	}
+	return path, nil
+}
@@ -0,0 +1,167 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/maestro-milagro/Post_Service_PB/internal/lib/sl"
+	"github.com/maestro-milagro/Post_Service_PB/internal/models"
+	"log/slog"
+	"time"
+)
+
+type Store interface {
+	ClaimOutboxDB(ctx context.Context, limit int, lease time.Duration) ([]models.OutboxEvent, error)
+	MarkDeliveredDB(ctx context.Context, id int64) error
+	MarkFailedDB(ctx context.Context, id int64, reason string, retryAt time.Time) error
+	PurgeOutboxDB(ctx context.Context, before time.Time, limit int) (int64, error)
+}
+
+type Publisher interface {
+	ProduceSync(ctx context.Context, post models.Post, topic string) error
+}
+
+// Config tunes the relay. Delivered events are kept for Retention, then purged.
+type Config struct {
+	PollInterval time.Duration
+	BatchSize    int
+	Lease        time.Duration
+	MinBackoff   time.Duration
+	MaxBackoff   time.Duration
+	Retention    time.Duration
+}
+
+// purgeInterval is how often delivered events past retention are deleted.
+const purgeInterval = 10 * time.Minute
+
+// Relay publishes events written to the outbox table and marks them delivered.
+// Delivery is at-least-once: an event is re-sent until the broker acknowledges it.
+type Relay struct {
+	log       *slog.Logger
+	cfg       Config
+	store     Store
+	publisher Publisher
+}
+
+func New(log *slog.Logger, cfg Config, store Store, publisher Publisher) *Relay {
+	return &Relay{
+		log:       log.With(slog.String("component", "outbox.Relay")),
+		cfg:       cfg,
+		store:     store,
+		publisher: publisher,
+	}
+}
+
+// Run polls the outbox until ctx is cancelled.
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.cfg.PollInterval)
+	defer ticker.Stop()
+
+	var purged time.Time
+
+	for {
+		if time.Since(purged) >= purgeInterval {
+			r.purge(ctx)
+			purged = time.Now()
+		}
+
+		// Пока пачки приходят полными, разбираем очередь без ожидания тика
+		for {
+			n, err := r.relayBatch(ctx)
+			if err != nil {
+				r.log.Error("failed to relay outbox batch", sl.Err(err))
+				break
+			}
+			if n < r.cfg.BatchSize || ctx.Err() != nil {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *Relay) relayBatch(ctx context.Context) (int, error) {
+	events, err := r.store.ClaimOutboxDB(ctx, r.cfg.BatchSize, r.cfg.Lease)
+	if err != nil {
+		return 0, err
+	}
+
+	// Если событие автора не ушло, его следующие события ждут, чтобы не нарушить порядок
+	blocked := make(map[string]bool)
+
+	for _, event := range events {
+		if blocked[event.Key] {
+			continue
+		}
+
+		if err := r.publish(ctx, event); err != nil {
+			blocked[event.Key] = true
+
+			retryAt := time.Now().Add(r.backoff(event.Attempts))
+			r.log.Warn("failed to publish outbox event",
+				slog.Int64("event_id", event.ID),
+				slog.Int("attempts", event.Attempts+1),
+				slog.Time("retry_at", retryAt),
+				sl.Err(err),
+			)
+
+			if err := r.store.MarkFailedDB(ctx, event.ID, err.Error(), retryAt); err != nil {
+				return len(events), err
+			}
+			continue
+		}
+
+		if err := r.store.MarkDeliveredDB(ctx, event.ID); err != nil {
+			// Событие уже в Kafka: после истечения аренды оно уйдёт повторно, что допустимо при at-least-once
+			return len(events), err
+		}
+	}
+
+	return len(events), nil
+}
+
+// purge deletes the events delivered longer than Retention ago, a batch at a time.
+func (r *Relay) purge(ctx context.Context) {
+	before := time.Now().Add(-r.cfg.Retention)
+
+	var total int64
+	for ctx.Err() == nil {
+		n, err := r.store.PurgeOutboxDB(ctx, before, r.cfg.BatchSize)
+		if err != nil {
+			r.log.Error("failed to purge delivered events", sl.Err(err))
+			break
+		}
+		total += n
+		if n < int64(r.cfg.BatchSize) {
+			break
+		}
+	}
+
+	if total > 0 {
+		r.log.Info("delivered events purged", slog.Int64("count", total))
+	}
+}
+
+func (r *Relay) publish(ctx context.Context, event models.OutboxEvent) error {
+	var post models.Post
+	if err := json.Unmarshal(event.Payload, &post); err != nil {
+		return err
+	}
+
+	return r.publisher.ProduceSync(ctx, post, event.Topic)
+}
+
+func (r *Relay) backoff(attempts int) time.Duration {
+	d := r.cfg.MinBackoff
+	for i := 0; i < attempts && d < r.cfg.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > r.cfg.MaxBackoff {
+		d = r.cfg.MaxBackoff
+	}
+	return d
+}
@@ -0,0 +1,127 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/maestro-milagro/Post_Service_PB/internal/models"
+)
+
+type fakeStore struct {
+	events    []models.OutboxEvent
+	delivered []int64
+	failed    []int64
+	purgeLeft int64
+	purges    int
+}
+
+func (s *fakeStore) ClaimOutboxDB(context.Context, int, time.Duration) ([]models.OutboxEvent, error) {
+	events := s.events
+	s.events = nil
+	return events, nil
+}
+
+func (s *fakeStore) MarkDeliveredDB(_ context.Context, id int64) error {
+	s.delivered = append(s.delivered, id)
+	return nil
+}
+
+func (s *fakeStore) MarkFailedDB(_ context.Context, id int64, _ string, _ time.Time) error {
+	s.failed = append(s.failed, id)
+	return nil
+}
+
+func (s *fakeStore) PurgeOutboxDB(_ context.Context, _ time.Time, limit int) (int64, error) {
+	s.purges++
+	n := min(s.purgeLeft, int64(limit))
+	s.purgeLeft -= n
+	return n, nil
+}
+
+type fakePublisher struct {
+	fail map[int]bool
+}
+
+func (p fakePublisher) ProduceSync(_ context.Context, post models.Post, _ string) error {
+	if p.fail[post.PostID] {
+		return errors.New("broker unavailable")
+	}
+	return nil
+}
+
+func event(id int64, key string, postID int) models.OutboxEvent {
+	payload := []byte(fmt.Sprintf(`{"post_id":%d,"email":%q}`, postID, key))
+	return models.OutboxEvent{ID: id, Topic: "posts", Key: key, Payload: payload}
+}
+
+func newRelay(store Store, publisher Publisher) *Relay {
+	return New(slog.New(slog.NewTextHandler(io.Discard, nil)), Config{
+		BatchSize:  2,
+		MinBackoff: time.Second,
+		MaxBackoff: time.Minute,
+		Retention:  time.Hour,
+	}, store, publisher)
+}
+
+func TestRelayBatchKeepsKeyOrder(t *testing.T) {
+	store := &fakeStore{events: []models.OutboxEvent{
+		event(1, "a", 1),
+		event(2, "b", 2),
+		event(3, "a", 3),
+		event(4, "b", 4),
+	}}
+	relay := newRelay(store, fakePublisher{fail: map[int]bool{1: true}})
+
+	if _, err := relay.relayBatch(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Событие 3 ждёт, пока не уйдёт событие 1 того же ключа
+	if want := []int64{2, 4}; !slices.Equal(store.delivered, want) {
+		t.Errorf("delivered %v, want %v", store.delivered, want)
+	}
+	if want := []int64{1}; !slices.Equal(store.failed, want) {
+		t.Errorf("failed %v, want %v", store.failed, want)
+	}
+}
+
+func TestRelayPurge(t *testing.T) {
+	store := &fakeStore{purgeLeft: 5}
+	relay := newRelay(store, fakePublisher{})
+
+	relay.purge(context.Background())
+
+	if store.purgeLeft != 0 {
+		t.Errorf("%d events left unpurged", store.purgeLeft)
+	}
+	// Пачки по 2: 2, 2, 1
+	if store.purges != 3 {
+		t.Errorf("purged in %d batches, want 3", store.purges)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	relay := newRelay(&fakeStore{}, fakePublisher{})
+
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{0, time.Second},
+		{1, 2 * time.Second},
+		{5, 32 * time.Second},
+		{6, time.Minute},
+		{100, time.Minute},
+	}
+	for _, tt := range tests {
+		if got := relay.backoff(tt.attempts); got != tt.want {
+			t.Errorf("backoff(%d) = %s, want %s", tt.attempts, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package service
+
+import (
+	"errors"
+	"github.com/maestro-milagro/Post_Service_PB/internal/models"
+	"golang.org/x/crypto/bcrypt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+var (
+	ErrPasswordRequired = errors.New("password required")
+	ErrWrongPassword    = errors.New("wrong password")
+	ErrTooManyAttempts  = errors.New("too many password attempts")
+	ErrPasswordTooLong  = errors.New("password is longer than 72 bytes")
+)
+
+// PasswordHeader carries the download password of a protected post.
+const PasswordHeader = "X-Paste-Password"
+
+// HashPassword returns the bcrypt hash stored in PostUser.PasswordHash.
+func HashPassword(password string) ([]byte, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if errors.Is(err, bcrypt.ErrPasswordTooLong) {
+		return nil, ErrPasswordTooLong
+	}
+	return hash, err
+}
+
+// PasswordGate checks download passwords of protected posts. Attempts are counted
+// per post; after maxAttempts wrong ones within window the post is locked for
+// everyone but its author until the window ends, whatever password is sent.
+// The counters live in the memory of the process, so each instance of the
+// service allows maxAttempts of its own.
+type PasswordGate struct {
+	log         *slog.Logger
+	maxAttempts int
+	window      time.Duration
+
+	mu       sync.Mutex
+	failures map[int64]*failures
+}
+
+type failures struct {
+	count int
+	since time.Time
+}
+
+// sweepThreshold is the number of tracked posts above which stale entries are dropped.
+const sweepThreshold = 10000
+
+func NewPasswordGate(log *slog.Logger, maxAttempts int, window time.Duration) *PasswordGate {
+	return &PasswordGate{
+		log:         log,
+		maxAttempts: maxAttempts,
+		window:      window,
+		failures:    make(map[int64]*failures),
+	}
+}
+
+// Check lets viewer download post with the given password. On ErrTooManyAttempts
+// the returned duration tells when the post is unlocked.
+func (g *PasswordGate) Check(post models.PostUser, viewer string, password string) (time.Duration, error) {
+	const op = "service.PasswordGate.Check"
+
+	if !post.Protected() || post.Email == viewer {
+		return 0, nil
+	}
+
+	now := time.Now()
+	if password == "" {
+		if wait := g.locked(post.ID, now); wait > 0 {
+			return wait, ErrTooManyAttempts
+		}
+		return 0, ErrPasswordRequired
+	}
+
+	// Попытка засчитывается до сравнения, иначе параллельные запросы прошли бы проверку все разом
+	attempt, wait := g.reserve(post.ID, now)
+	if wait > 0 {
+		return wait, ErrTooManyAttempts
+	}
+
+	if err := bcrypt.CompareHashAndPassword(post.PasswordHash, []byte(password)); err != nil {
+		if attempt == g.maxAttempts {
+			g.log.Warn("post locked after wrong passwords",
+				slog.String("op", op),
+				slog.Int64("post_id", post.ID),
+			)
+		}
+		return 0, ErrWrongPassword
+	}
+
+	g.mu.Lock()
+	delete(g.failures, post.ID)
+	g.mu.Unlock()
+
+	return 0, nil
+}
+
+func (g *PasswordGate) locked(id int64, now time.Time) time.Duration {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	f, ok := g.failures[id]
+	if !ok || f.count < g.maxAttempts || now.Sub(f.since) >= g.window {
+		return 0
+	}
+	return f.since.Add(g.window).Sub(now)
+}
+
+// reserve counts an attempt before the password is compared and returns its number.
+// A locked post gets no attempt and the time left until it is unlocked instead.
+func (g *PasswordGate) reserve(id int64, now time.Time) (int, time.Duration) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	f, ok := g.failures[id]
+	if !ok || now.Sub(f.since) >= g.window {
+		if len(g.failures) >= sweepThreshold {
+			g.sweep(now)
+		}
+		f = &failures{since: now}
+		g.failures[id] = f
+	}
+	if f.count >= g.maxAttempts {
+		return 0, f.since.Add(g.window).Sub(now)
+	}
+	f.count++
+
+	return f.count, 0
+}
+
+func (g *PasswordGate) sweep(now time.Time) {
+	for id, f := range g.failures {
+		if now.Sub(f.since) >= g.window {
+			delete(g.failures, id)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/maestro-milagro/Post_Service_PB/internal/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func protectedPost(t *testing.T, password string) models.PostUser {
+	t.Helper()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return models.PostUser{ID: 1, Email: "author@example.com", PasswordHash: hash}
+}
+
+func TestPasswordGateCheck(t *testing.T) {
+	gate := NewPasswordGate(slog.New(slog.NewTextHandler(io.Discard, nil)), 2, time.Minute)
+	post := protectedPost(t, "secret")
+
+	steps := []struct {
+		viewer   string
+		password string
+		want     error
+	}{
+		{"author@example.com", "", nil},
+		{"reader@example.com", "", ErrPasswordRequired},
+		{"reader@example.com", "secret", nil},
+		{"reader@example.com", "wrong", ErrWrongPassword},
+		{"reader@example.com", "wrong", ErrWrongPassword},
+		{"reader@example.com", "secret", ErrTooManyAttempts},
+		{"reader@example.com", "", ErrTooManyAttempts},
+		{"author@example.com", "", nil},
+	}
+
+	for i, step := range steps {
+		wait, err := gate.Check(post, step.viewer, step.password)
+		if !errors.Is(err, step.want) {
+			t.Fatalf("step %d: got %v, want %v", i, err, step.want)
+		}
+		if errors.Is(err, ErrTooManyAttempts) && (wait <= 0 || wait > time.Minute) {
+			t.Errorf("step %d: wait %s out of range", i, wait)
+		}
+	}
+}
+
+func TestPasswordGateConcurrentGuesses(t *testing.T) {
+	const maxAttempts = 3
+
+	gate := NewPasswordGate(slog.New(slog.NewTextHandler(io.Discard, nil)), maxAttempts, time.Minute)
+	post := protectedPost(t, "secret")
+
+	var compared atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := gate.Check(post, "reader@example.com", "wrong"); errors.Is(err, ErrWrongPassword) {
+				compared.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := compared.Load(); n != maxAttempts {
+		t.Errorf("%d guesses were compared, want %d", n, maxAttempts)
+	}
+}
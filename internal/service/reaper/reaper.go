@@ -0,0 +1,127 @@
+package reaper
+
+import (
+	"context"
+	"expvar"
+	"github.com/maestro-milagro/Post_Service_PB/internal/lib/sl"
+	"github.com/maestro-milagro/Post_Service_PB/internal/models"
+	"github.com/maestro-milagro/Post_Service_PB/internal/service"
+	"log/slog"
+	"time"
+)
+
+// Counters are published on /debug/vars.
+var (
+	postsReaped   = expvar.NewInt("reaper_posts_reaped_total")
+	objectsReaped = expvar.NewInt("reaper_objects_deleted_total")
+	reapErrors    = expvar.NewInt("reaper_errors_total")
+)
+
+type Store interface {
+	ExpiredPostsDB(ctx context.Context, now time.Time, limit int) ([]models.PostUser, error)
+	DeletePostsDB(ctx context.Context, ids []int64) (int64, error)
+	RevisionKeysDB(ctx context.Context, ids []int64) ([]models.PostRevision, error)
+}
+
+// Reaper removes expired posts. Objects are deleted before rows: an expired row already
+// answers 410, so if deleting its object fails the row simply stays for the next pass,
+// and nothing is left orphaned in the bucket.
+type Reaper struct {
+	log       *slog.Logger
+	store     Store
+	blobs     service.BlobStore
+	interval  time.Duration
+	batchSize int
+}
+
+func New(log *slog.Logger, store Store, blobs service.BlobStore, interval time.Duration, batchSize int) *Reaper {
+	return &Reaper{
+		log:       log.With(slog.String("component", "reaper.Reaper")),
+		store:     store,
+		blobs:     blobs,
+		interval:  interval,
+		batchSize: batchSize,
+	}
+}
+
+// Run reaps on every tick until ctx is cancelled.
+func (r *Reaper) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		// Пока пачки полные, продолжаем без ожидания следующего тика
+		for ctx.Err() == nil {
+			n, err := r.reapBatch(ctx)
+			if err != nil {
+				reapErrors.Add(1)
+				r.log.Error("failed to reap expired posts", sl.Err(err))
+				break
+			}
+			if n < r.batchSize {
+				break
+			}
+		}
+	}
+}
+
+func (r *Reaper) reapBatch(ctx context.Context) (int, error) {
+	posts, err := r.store.ExpiredPostsDB(ctx, time.Now(), r.batchSize)
+	if err != nil {
+		return 0, err
+	}
+	if len(posts) == 0 {
+		return 0, nil
+	}
+
+	ids := make([]int64, 0, len(posts))
+	buckets := make(map[int64]string, len(posts))
+	for _, post := range posts {
+		ids = append(ids, post.ID)
+		buckets[post.ID] = post.Bucket
+	}
+
+	// Удаляем объекты всех ревизий, текущая среди них
+	revs, err := r.store.RevisionKeysDB(ctx, ids)
+	if err != nil {
+		return 0, err
+	}
+
+	keysByBucket := make(map[string][]string)
+	seen := make(map[string]bool)
+	add := func(bucket, key string) {
+		if !seen[bucket+"/"+key] {
+			seen[bucket+"/"+key] = true
+			keysByBucket[bucket] = append(keysByBucket[bucket], key)
+		}
+	}
+	for _, post := range posts {
+		add(post.Bucket, post.Key)
+	}
+	for _, rev := range revs {
+		add(buckets[rev.PostID], rev.Key)
+	}
+
+	for bucket, keys := range keysByBucket {
+		if err := r.blobs.DeleteObjects(ctx, bucket, keys); err != nil {
+			return 0, err
+		}
+		objectsReaped.Add(int64(len(keys)))
+	}
+
+	deleted, err := r.store.DeletePostsDB(ctx, ids)
+	if err != nil {
+		return 0, err
+	}
+	postsReaped.Add(deleted)
+
+	r.log.Info("expired posts reaped", slog.Int64("count", deleted))
+
+	return len(posts), nil
+}
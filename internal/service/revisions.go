@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/maestro-milagro/Post_Service_PB/internal/lib/sl"
+	"github.com/maestro-milagro/Post_Service_PB/internal/models"
+	"github.com/maestro-milagro/Post_Service_PB/internal/storage"
+	"log/slog"
+)
+
+var (
+	ErrNotOwner    = errors.New("post belongs to another user")
+	ErrNoRevision  = errors.New("revision not found")
+	ErrNotEditable = errors.New("burn-after-read posts cannot be edited")
+)
+
+type DBRevisions interface {
+	AddRevisionDB(ctx context.Context, email string, rev models.PostRevision) (models.PostRevision, error)
+	RestoreRevisionDB(ctx context.Context, email string, id int64, n int) (models.PostRevision, error)
+	RevisionsDB(ctx context.Context, id int64) ([]models.PostRevision, error)
+	RevisionDB(ctx context.Context, id int64, n int) (models.PostRevision, error)
+}
+
+// Editable returns the post if email may add revisions to it.
+func (s *Service) Editable(ctx context.Context, email string, id int) (models.PostUser, error) {
+	const op = "service.Editable"
+
+	post, err := s.GetById(ctx, email, id)
+	if err != nil {
+		return models.PostUser{}, fmt.Errorf("%s: %w", op, err)
+	}
+	// Одноразовый пост читается ровно один раз, новая ревизия это бы нарушила
+	if post.BurnAfterRead {
+		return models.PostUser{}, fmt.Errorf("%s: %w", op, ErrNotEditable)
+	}
+	return post, nil
+}
+
+// Edit makes rev, an already uploaded body, the current revision of the post.
+func (s *Service) Edit(ctx context.Context, email string, rev models.PostRevision) (models.PostRevision, error) {
+	const op = "service.Edit"
+
+	log := s.log.With(
+		slog.String("op", op),
+		slog.Int64("post_id", rev.PostID),
+	)
+
+	log.Info("adding revision")
+
+	rev, err := s.dbRevisions.AddRevisionDB(ctx, email, rev)
+	if err != nil {
+		log.Error("error while adding revision", sl.Err(err))
+
+		return models.PostRevision{}, fmt.Errorf("%s: %w", op, revisionErr(err))
+	}
+	return rev, nil
+}
+
+// Restore adds a new revision with the content of revision n.
+func (s *Service) Restore(ctx context.Context, email string, id int, n int) (models.PostRevision, error) {
+	const op = "service.Restore"
+
+	log := s.log.With(
+		slog.String("op", op),
+		slog.Int("post_id", id),
+		slog.Int("revision", n),
+	)
+
+	if _, err := s.Editable(ctx, email, id); err != nil {
+		return models.PostRevision{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("restoring revision")
+
+	rev, err := s.dbRevisions.RestoreRevisionDB(ctx, email, int64(id), n)
+	if err != nil {
+		log.Error("error while restoring revision", sl.Err(err))
+
+		return models.PostRevision{}, fmt.Errorf("%s: %w", op, revisionErr(err))
+	}
+	return rev, nil
+}
+
+// Revisions lists the revision history of the post to its owner, oldest first.
+func (s *Service) Revisions(ctx context.Context, email string, id int) ([]models.PostRevision, error) {
+	const op = "service.Revisions"
+
+	if _, err := s.GetById(ctx, email, id); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	revs, err := s.dbRevisions.RevisionsDB(ctx, int64(id))
+	if err != nil {
+		s.log.Error("error while listing revisions", slog.String("op", op), sl.Err(err))
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return revs, nil
+}
+
+// Revision returns the post as it was at revision n, to its owner.
+func (s *Service) Revision(ctx context.Context, email string, id int, n int) (models.PostUser, models.PostRevision, error) {
+	const op = "service.Revision"
+
+	post, err := s.GetById(ctx, email, id)
+	if err != nil {
+		return models.PostUser{}, models.PostRevision{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rev, err := s.dbRevisions.RevisionDB(ctx, int64(id), n)
+	if err != nil {
+		return models.PostUser{}, models.PostRevision{}, fmt.Errorf("%s: %w", op, revisionErr(err))
+	}
+	return post.AtRevision(rev), rev, nil
+}
+
+func revisionErr(err error) error {
+	switch {
+	case errors.Is(err, storage.ErrNotOwner):
+		return ErrNotOwner
+	case errors.Is(err, storage.ErrNoRevision):
+		return ErrNoRevision
+	}
+	return err
+}
@@ -0,0 +1,202 @@
+package revocation
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"github.com/maestro-milagro/Post_Service_PB/internal/lib/jwt"
+	"github.com/maestro-milagro/Post_Service_PB/internal/lib/sl"
+	"github.com/maestro-milagro/Post_Service_PB/internal/models"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+var ErrNoJTI = errors.New("token has no jti")
+
+// Counters are published on /debug/vars.
+var (
+	refreshErrors = expvar.NewInt("revocation_refresh_errors_total")
+	rejected      = expvar.NewInt("revocation_rejected_total")
+)
+
+type Store interface {
+	RevokeTokenDB(ctx context.Context, token models.RevokedToken) error
+	RevokeUserDB(ctx context.Context, user models.RevokedUser) error
+	RevokedTokensDB(ctx context.Context, now time.Time) ([]models.RevokedToken, error)
+	RevokedUsersDB(ctx context.Context) ([]models.RevokedUser, error)
+	DeleteExpiredRevocationsDB(ctx context.Context, now time.Time) (int64, error)
+}
+
+// Config sets how the list is kept. MaxTokenTTL is the longest lifetime of the
+// tokens the auth service issues: a jti revoked without its token is kept that
+// long. Leeway is the clock skew the verifier allows past exp.
+type Config struct {
+	RefreshInterval time.Duration
+	MaxTokenTTL     time.Duration
+	Leeway          time.Duration
+}
+
+// List keeps the revoked JWTs in memory so that checking a token costs no query.
+// Revocations made by this instance apply at once, those made by other instances
+// once the list is refreshed from the store.
+type List struct {
+	log   *slog.Logger
+	store Store
+	cfg   Config
+
+	mu     sync.RWMutex
+	tokens map[string]time.Time
+	users  map[string]time.Time
+}
+
+func New(log *slog.Logger, cfg Config, store Store) *List {
+	return &List{
+		log:    log.With(slog.String("component", "revocation.List")),
+		store:  store,
+		cfg:    cfg,
+		tokens: map[string]time.Time{},
+		users:  map[string]time.Time{},
+	}
+}
+
+// Load replaces the cached list with the revocations in the store.
+func (l *List) Load(ctx context.Context) error {
+	const op = "service.revocation.Load"
+
+	revokedTokens, err := l.store.RevokedTokensDB(ctx, time.Now())
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	revokedUsers, err := l.store.RevokedUsersDB(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	tokens := make(map[string]time.Time, len(revokedTokens))
+	for _, t := range revokedTokens {
+		tokens[t.JTI] = t.ExpiresAt
+	}
+	users := make(map[string]time.Time, len(revokedUsers))
+	for _, u := range revokedUsers {
+		users[u.Email] = u.RevokedBefore
+	}
+
+	l.mu.Lock()
+	l.tokens, l.users = tokens, users
+	l.mu.Unlock()
+
+	return nil
+}
+
+// Run refreshes the list on every tick until ctx is cancelled. A failed refresh
+// keeps the previous list.
+func (l *List) Run(ctx context.Context) {
+	ticker := time.NewTicker(l.cfg.RefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if _, err := l.store.DeleteExpiredRevocationsDB(ctx, time.Now()); err != nil {
+			refreshErrors.Add(1)
+			l.log.Error("failed to delete expired revocations", sl.Err(err))
+		}
+
+		if err := l.Load(ctx); err != nil {
+			refreshErrors.Add(1)
+			l.log.Error("failed to refresh revocations", sl.Err(err))
+		}
+	}
+}
+
+// Revoked implements auth.Revocations.
+func (l *List) Revoked(claims jwt.Claims) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	revoked := false
+	if _, ok := l.tokens[claims.ID]; claims.ID != "" && ok {
+		revoked = true
+	}
+	if before, ok := l.users[claims.Email]; ok {
+		// Без iat нельзя доказать, что токен выпущен после отзыва. iat округлён до секунды,
+		// поэтому токен, выпущенный в ту же секунду, что и отзыв, тоже отклоняется
+		if claims.IssuedAt.IsZero() || claims.IssuedAt.Before(before) {
+			revoked = true
+		}
+	}
+
+	if revoked {
+		rejected.Add(1)
+	}
+	return revoked
+}
+
+// RevokeToken revokes a verified token until it expires.
+func (l *List) RevokeToken(ctx context.Context, claims jwt.Claims) error {
+	const op = "service.revocation.RevokeToken"
+
+	if claims.ID == "" {
+		return fmt.Errorf("%s: %w", op, ErrNoJTI)
+	}
+	// Верификатор принимает токен ещё leeway после exp, столько же храним и запись
+	if err := l.revoke(ctx, claims.ID, claims.ExpiresAt.Add(l.cfg.Leeway)); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// RevokeJTI revokes the token with the given jti when the token itself is not
+// at hand. Its exp is unknown, so the record is kept for MaxTokenTTL.
+func (l *List) RevokeJTI(ctx context.Context, jti string) error {
+	const op = "service.revocation.RevokeJTI"
+
+	if err := l.revoke(ctx, jti, time.Now().Add(l.cfg.MaxTokenTTL+l.cfg.Leeway)); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (l *List) revoke(ctx context.Context, jti string, until time.Time) error {
+	if err := l.store.RevokeTokenDB(ctx, models.RevokedToken{JTI: jti, ExpiresAt: until}); err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	if until.After(l.tokens[jti]) {
+		l.tokens[jti] = until
+	}
+	l.mu.Unlock()
+
+	l.log.Info("token revoked", slog.String("jti", jti), slog.Time("until", until))
+
+	return nil
+}
+
+// RevokeUser revokes every token issued to email so far, API tokens included,
+// and returns the cutoff. Tokens issued afterwards are accepted.
+func (l *List) RevokeUser(ctx context.Context, email string) (time.Time, error) {
+	const op = "service.revocation.RevokeUser"
+
+	before := time.Now()
+
+	if err := l.store.RevokeUserDB(ctx, models.RevokedUser{Email: email, RevokedBefore: before}); err != nil {
+		return time.Time{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	l.mu.Lock()
+	if before.After(l.users[email]) {
+		l.users[email] = before
+	}
+	l.mu.Unlock()
+
+	l.log.Info("all tokens of user revoked", slog.String("user", email))
+
+	return before, nil
+}
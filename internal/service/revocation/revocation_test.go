@@ -0,0 +1,118 @@
+package revocation
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/maestro-milagro/Post_Service_PB/internal/lib/jwt"
+	"github.com/maestro-milagro/Post_Service_PB/internal/models"
+)
+
+type memStore struct {
+	tokens []models.RevokedToken
+	users  []models.RevokedUser
+}
+
+func (m *memStore) RevokeTokenDB(_ context.Context, token models.RevokedToken) error {
+	m.tokens = append(m.tokens, token)
+	return nil
+}
+
+func (m *memStore) RevokeUserDB(_ context.Context, user models.RevokedUser) error {
+	m.users = append(m.users, user)
+	return nil
+}
+
+func (m *memStore) RevokedTokensDB(_ context.Context, now time.Time) ([]models.RevokedToken, error) {
+	var out []models.RevokedToken
+	for _, t := range m.tokens {
+		if t.ExpiresAt.After(now) {
+			out = append(out, t)
+		}
+	}
+	return out, nil
+}
+
+func (m *memStore) RevokedUsersDB(context.Context) ([]models.RevokedUser, error) {
+	return m.users, nil
+}
+
+func (m *memStore) DeleteExpiredRevocationsDB(context.Context, time.Time) (int64, error) {
+	return 0, nil
+}
+
+func newList(store Store) *List {
+	return New(slog.New(slog.NewTextHandler(io.Discard, nil)), Config{
+		RefreshInterval: time.Minute,
+		MaxTokenTTL:     24 * time.Hour,
+		Leeway:          30 * time.Second,
+	}, store)
+}
+
+func TestRevokeToken(t *testing.T) {
+	store := &memStore{}
+	list := newList(store)
+	exp := time.Now().Add(time.Hour)
+
+	if err := list.RevokeToken(context.Background(), jwt.Claims{ID: "leaked", ExpiresAt: exp}); err != nil {
+		t.Fatal(err)
+	}
+	if got := store.tokens[0].ExpiresAt; !got.Equal(exp.Add(30 * time.Second)) {
+		t.Errorf("record kept until %s, want exp plus leeway", got)
+	}
+
+	if err := list.RevokeToken(context.Background(), jwt.Claims{ExpiresAt: exp}); !errors.Is(err, ErrNoJTI) {
+		t.Errorf("token without jti: got %v, want ErrNoJTI", err)
+	}
+
+	if err := list.RevokeJTI(context.Background(), "unknown-exp"); err != nil {
+		t.Fatal(err)
+	}
+	if got := time.Until(store.tokens[1].ExpiresAt); got < 24*time.Hour {
+		t.Errorf("jti without token kept for %s, want at least MaxTokenTTL", got)
+	}
+
+	// Другой экземпляр узнаёт об отзыве после обновления списка
+	other := newList(store)
+	if err := other.Load(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	for _, l := range []*List{list, other} {
+		if !l.Revoked(jwt.Claims{ID: "leaked", Email: "a@example.com"}) {
+			t.Error("revoked jti is accepted")
+		}
+		if l.Revoked(jwt.Claims{ID: "fresh", Email: "a@example.com", IssuedAt: time.Now()}) {
+			t.Error("unrelated token is rejected")
+		}
+	}
+}
+
+func TestRevokeUser(t *testing.T) {
+	list := newList(&memStore{})
+
+	issued := time.Now().Add(-time.Minute)
+	before, err := list.RevokeUser(context.Background(), "a@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		claims jwt.Claims
+		want   bool
+	}{
+		{"issued before", jwt.Claims{Email: "a@example.com", IssuedAt: issued}, true},
+		{"without iat", jwt.Claims{Email: "a@example.com"}, true},
+		{"issued after", jwt.Claims{Email: "a@example.com", IssuedAt: before.Add(time.Second)}, false},
+		{"other user", jwt.Claims{Email: "b@example.com", IssuedAt: issued}, false},
+	}
+	for _, tt := range tests {
+		if got := list.Revoked(tt.claims); got != tt.want {
+			t.Errorf("%s: Revoked = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package rotation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/maestro-milagro/Post_Service_PB/internal/lib/sl"
+	"github.com/maestro-milagro/Post_Service_PB/internal/models"
+	"github.com/maestro-milagro/Post_Service_PB/internal/service/envelope"
+	"github.com/maestro-milagro/Post_Service_PB/internal/storage"
+	"log/slog"
+)
+
+type Store interface {
+	StaleKeyPostsDB(ctx context.Context, activeKeyID string, afterID int64, limit int) ([]models.PostUser, error)
+	UpdateDataKeyDB(ctx context.Context, id int64, oldKeyID string, newKeyID string, dataKey []byte) error
+	StaleKeyRevisionsDB(ctx context.Context, activeKeyID string, after models.PostRevision, limit int) ([]models.PostRevision, error)
+	UpdateRevisionDataKeyDB(ctx context.Context, rev models.PostRevision, newKeyID string, dataKey []byte) error
+}
+
+type Stats struct {
+	Rewrapped int
+	Skipped   int
+}
+
+// KeyRotator rewraps the data keys of encrypted posts and their revisions with the active master key.
+// Objects are not touched: their data keys stay the same, only the wrapping changes.
+// Once it has finished, retired master keys can be removed from the config.
+type KeyRotator struct {
+	log       *slog.Logger
+	store     Store
+	keys      *envelope.Keyring
+	batchSize int
+	dryRun    bool
+}
+
+func NewKeyRotator(log *slog.Logger, store Store, keys *envelope.Keyring, batchSize int, dryRun bool) *KeyRotator {
+	return &KeyRotator{
+		log:       log.With(slog.String("component", "rotation.KeyRotator")),
+		store:     store,
+		keys:      keys,
+		batchSize: batchSize,
+		dryRun:    dryRun,
+	}
+}
+
+func (k *KeyRotator) Run(ctx context.Context) (Stats, error) {
+	const op = "service.rotation.KeyRotator.Run"
+
+	if !k.keys.Enabled() {
+		return Stats{}, fmt.Errorf("%s: %w", op, envelope.ErrNoActive)
+	}
+
+	stats, err := k.rotatePosts(ctx)
+	if err != nil {
+		return stats, fmt.Errorf("%s: %w", op, err)
+	}
+
+	revStats, err := k.rotateRevisions(ctx)
+	stats.Rewrapped += revStats.Rewrapped
+	stats.Skipped += revStats.Skipped
+	if err != nil {
+		return stats, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return stats, nil
+}
+
+func (k *KeyRotator) rotatePosts(ctx context.Context) (Stats, error) {
+	var stats Stats
+	var afterID int64
+
+	for {
+		posts, err := k.store.StaleKeyPostsDB(ctx, k.keys.Active(), afterID, k.batchSize)
+		if err != nil {
+			return stats, err
+		}
+		if len(posts) == 0 {
+			return stats, nil
+		}
+
+		for _, post := range posts {
+			afterID = post.ID
+
+			if k.dryRun {
+				k.log.Info("would rewrap data key", slog.Int64("post_id", post.ID), slog.String("key_id", post.EncKeyID))
+				stats.Rewrapped++
+				continue
+			}
+
+			keyID, dataKey, err := k.keys.Rewrap(post.EncKeyID, post.EncDataKey)
+			if err != nil {
+				return stats, fmt.Errorf("post %d: %w", post.ID, err)
+			}
+
+			err = k.store.UpdateDataKeyDB(ctx, post.ID, post.EncKeyID, keyID, dataKey)
+			if err != nil {
+				// Пост удалён или уже перешифрован параллельно
+				if errors.Is(err, storage.ErrPostNotFound) {
+					k.log.Info("post changed concurrently, skipping", slog.Int64("post_id", post.ID), sl.Err(err))
+					stats.Skipped++
+					continue
+				}
+				return stats, fmt.Errorf("post %d: %w", post.ID, err)
+			}
+			stats.Rewrapped++
+		}
+	}
+}
+
+func (k *KeyRotator) rotateRevisions(ctx context.Context) (Stats, error) {
+	var stats Stats
+	var after models.PostRevision
+
+	for {
+		revs, err := k.store.StaleKeyRevisionsDB(ctx, k.keys.Active(), after, k.batchSize)
+		if err != nil {
+			return stats, err
+		}
+		if len(revs) == 0 {
+			return stats, nil
+		}
+
+		for _, rev := range revs {
+			after = rev
+
+			if k.dryRun {
+				k.log.Info("would rewrap revision data key",
+					slog.Int64("post_id", rev.PostID),
+					slog.Int("revision", rev.Revision),
+					slog.String("key_id", rev.EncKeyID),
+				)
+				stats.Rewrapped++
+				continue
+			}
+
+			keyID, dataKey, err := k.keys.Rewrap(rev.EncKeyID, rev.EncDataKey)
+			if err != nil {
+				return stats, fmt.Errorf("post %d revision %d: %w", rev.PostID, rev.Revision, err)
+			}
+
+			err = k.store.UpdateRevisionDataKeyDB(ctx, rev, keyID, dataKey)
+			if err != nil {
+				if errors.Is(err, storage.ErrNoRevision) {
+					k.log.Info("revision changed concurrently, skipping",
+						slog.Int64("post_id", rev.PostID),
+						slog.Int("revision", rev.Revision),
+					)
+					stats.Skipped++
+					continue
+				}
+				return stats, fmt.Errorf("post %d revision %d: %w", rev.PostID, rev.Revision, err)
+			}
+			stats.Rewrapped++
+		}
+	}
+}
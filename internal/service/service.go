@@ -9,12 +9,16 @@ import (
 	"github.com/maestro-milagro/Post_Service_PB/internal/storage"
 	"log/slog"
 	"strconv"
+	"time"
 )
 
 var (
-	ErrUserNotFound      = errors.New("no users found")
-	ErrSubscriptionExist = errors.New("subscription already exists")
-	ErrNoFollowers       = errors.New("no followers found")
+	ErrUserNotFound     = errors.New("no users found")
+	ErrAuthorNotFound   = errors.New("author not found")
+	ErrSelfSubscription = errors.New("users cannot subscribe to themselves")
+	ErrNoFollowers      = errors.New("no followers found")
+	ErrPostExpired      = errors.New("post expired")
+	ErrPostConsumed     = errors.New("post already read")
 )
 
 type Service struct {
@@ -22,69 +26,123 @@ type Service struct {
 	dbSubscriber DBSubscriber
 	dbPostSaver  DBPostSaver
 	dbByIDGetter DBByIDGetter
+	dbBySlug     DBBySlugGetter
 	dbAllGetter  DBAllGetter
 	dbDeleter    DBDeleter
 	dbWhoSubbed  DBWhoSubbed
+	dbFeed       DBFeed
+	dbUserGetter DBUserGetter
+	dbBurner     DBBurner
+	dbFollows    DBFollowChecker
+	dbRevisions  DBRevisions
+	dbForks      DBForks
+	gate         *PasswordGate
 }
 
 func New(log *slog.Logger,
 	dbSubscriber DBSubscriber,
 	dbPostSaver DBPostSaver,
 	dbByIDGetter DBByIDGetter,
+	dbBySlug DBBySlugGetter,
 	dbAllGetter DBAllGetter,
 	dbDeleter DBDeleter,
-	dbWhoSubbed DBWhoSubbed) *Service {
+	dbWhoSubbed DBWhoSubbed,
+	dbFeed DBFeed,
+	dbUserGetter DBUserGetter,
+	dbBurner DBBurner,
+	dbFollows DBFollowChecker,
+	dbRevisions DBRevisions,
+	dbForks DBForks,
+	gate *PasswordGate) *Service {
 	return &Service{
 		log:          log,
 		dbSubscriber: dbSubscriber,
 		dbPostSaver:  dbPostSaver,
 		dbByIDGetter: dbByIDGetter,
+		dbBySlug:     dbBySlug,
 		dbAllGetter:  dbAllGetter,
 		dbDeleter:    dbDeleter,
 		dbWhoSubbed:  dbWhoSubbed,
+		dbFeed:       dbFeed,
+		dbUserGetter: dbUserGetter,
+		dbBurner:     dbBurner,
+		dbFollows:    dbFollows,
+		dbRevisions:  dbRevisions,
+		dbForks:      dbForks,
+		gate:         gate,
 	}
 }
 
 type DBSubscriber interface {
 	SubscribeDB(ctx context.Context, uid int, subId int) error
+	UnsubscribeDB(ctx context.Context, uid int, subId int) error
 }
 
 type DBPostSaver interface {
-	PostSaveDB(ctx context.Context, user models.PostUser) (int64, error)
+	PostSaveDB(ctx context.Context, user models.PostUser) (int64, string, error)
 }
 
 type DBByIDGetter interface {
 	GetByIdDB(ctx context.Context, id int) (models.PostUser, error)
 }
 
+type DBBySlugGetter interface {
+	GetBySlugDB(ctx context.Context, slug string) (models.PostUser, error)
+}
+
 type DBAllGetter interface {
-	GetAllDB(ctx context.Context) ([]models.PostUser, error)
+	GetAllDB(ctx context.Context, filter models.PostFilter) ([]models.PostUser, error)
 }
 
 type DBDeleter interface {
-	DeleteDB(ctx context.Context, ids []int) ([]string, error)
+	DeleteDB(ctx context.Context, email string, isAdmin bool, ids []int) ([]models.DeleteResult, error)
 }
 
 type DBWhoSubbed interface {
 	WhoSubbedDB(ctx context.Context, email string) ([]int, error)
 }
 
-func (s *Service) Subscribe(ctx context.Context, uid int, subId int) error {
+type DBFeed interface {
+	AddToFeedDB(ctx context.Context, post models.Post, followerIDs []int) error
+	FeedDB(ctx context.Context, userID int, cursor int64, limit int) ([]models.FeedItem, error)
+}
+
+type DBUserGetter interface {
+	UserIDByEmailDB(ctx context.Context, email string) (int, error)
+}
+
+type DBBurner interface {
+	ConsumeDB(ctx context.Context, id int) (time.Time, error)
+	ReleaseConsumeDB(ctx context.Context, id int, consumedAt time.Time) error
+}
+
+type DBFollowChecker interface {
+	IsFollowerDB(ctx context.Context, followerEmail string, authorEmail string) (bool, error)
+}
+
+// Subscribe makes the user with email a follower of authorID. Subscribing again is not an error.
+func (s *Service) Subscribe(ctx context.Context, email string, authorID int) error {
 	const op = "service.Subscribe"
 
 	log := s.log.With(
 		slog.String("op", op),
-		slog.Int64("userID", int64(uid)),
+		slog.String("user", email),
+		slog.Int("author_id", authorID),
 	)
 
+	followerID, err := s.follower(ctx, email, authorID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
 	log.Info("subscribing user")
 
-	err := s.dbSubscriber.SubscribeDB(ctx, uid, subId)
+	err = s.dbSubscriber.SubscribeDB(ctx, authorID, followerID)
 	if err != nil {
-		if errors.Is(err, storage.ErrSubExist) {
-			s.log.Warn("already following", sl.Err(err))
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Info("author not found", sl.Err(err))
 
-			return fmt.Errorf("%s: %w", op, ErrSubscriptionExist)
+			return fmt.Errorf("%s: %w", op, ErrAuthorNotFound)
 		}
 
 		return fmt.Errorf("%s: %w", op, err)
@@ -92,7 +150,47 @@ func (s *Service) Subscribe(ctx context.Context, uid int, subId int) error {
 	return nil
 }
 
-func (s *Service) SavePost(ctx context.Context, user models.PostUser) (int64, error) {
+// Unsubscribe stops the user with email from following authorID. Unsubscribing
+// when not subscribed is not an error.
+func (s *Service) Unsubscribe(ctx context.Context, email string, authorID int) error {
+	const op = "service.Unsubscribe"
+
+	log := s.log.With(
+		slog.String("op", op),
+		slog.String("user", email),
+		slog.Int("author_id", authorID),
+	)
+
+	followerID, err := s.follower(ctx, email, authorID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("unsubscribing user")
+
+	if err := s.dbSubscriber.UnsubscribeDB(ctx, authorID, followerID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// follower resolves the id of the subscribing user, which must not be the author.
+func (s *Service) follower(ctx context.Context, email string, authorID int) (int, error) {
+	followerID, err := s.dbUserGetter.UserIDByEmailDB(ctx, email)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return 0, ErrUserNotFound
+		}
+		return 0, err
+	}
+	if followerID == authorID {
+		return 0, ErrSelfSubscription
+	}
+	return followerID, nil
+}
+
+// SavePost stores the post and returns its id and public slug.
+func (s *Service) SavePost(ctx context.Context, user models.PostUser) (int64, string, error) {
 	const op = "service.SavePost"
 
 	log := s.log.With(
@@ -102,16 +200,18 @@ func (s *Service) SavePost(ctx context.Context, user models.PostUser) (int64, er
 
 	log.Info("saving post")
 
-	id, err := s.dbPostSaver.PostSaveDB(ctx, user)
+	id, slug, err := s.dbPostSaver.PostSaveDB(ctx, user)
 	if err != nil {
 		log.Error("error while saving post", sl.Err(err))
 
-		return 0, fmt.Errorf("%s: %w", op, err)
+		return 0, "", fmt.Errorf("%s: %w", op, err)
 	}
-	return id, nil
+	return id, slug, nil
 }
 
-func (s *Service) GetById(ctx context.Context, id int) (models.PostUser, error) {
+// GetById returns the post to its author. Sequential ids are easy to enumerate, so
+// everyone else has to use the slug; their lookups are reported as storage.ErrPostNotFound.
+func (s *Service) GetById(ctx context.Context, viewer string, id int) (models.PostUser, error) {
 	const op = "service.GetById"
 
 	log := s.log.With(
@@ -127,10 +227,81 @@ func (s *Service) GetById(ctx context.Context, id int) (models.PostUser, error)
 
 		return models.PostUser{}, fmt.Errorf("%s: %w", op, err)
 	}
+	if userPost.Email != viewer {
+		log.Info("post requested by id by a non-owner")
+
+		return models.PostUser{}, fmt.Errorf("%s: %w", op, storage.ErrPostNotFound)
+	}
+	if err := readable(userPost); err != nil {
+		return models.PostUser{}, fmt.Errorf("%s: %w", op, err)
+	}
 	return userPost, nil
 }
 
-func (s *Service) GetAll(ctx context.Context) ([]models.PostUser, error) {
+// GetBySlug returns the post if viewer is allowed to see it. Posts hidden from
+// the viewer are reported as storage.ErrPostNotFound so their existence is not disclosed.
+func (s *Service) GetBySlug(ctx context.Context, viewer string, slug string) (models.PostUser, error) {
+	const op = "service.GetBySlug"
+
+	log := s.log.With(
+		slog.String("op", op),
+		slog.String("slug", slug),
+	)
+
+	log.Info("getting by slug")
+
+	userPost, err := s.dbBySlug.GetBySlugDB(ctx, slug)
+	if err != nil {
+		log.Error("error while getting by slug", sl.Err(err))
+
+		return models.PostUser{}, fmt.Errorf("%s: %w", op, err)
+	}
+	visible, err := s.canView(ctx, viewer, userPost)
+	if err != nil {
+		log.Error("error while checking visibility", sl.Err(err))
+
+		return models.PostUser{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if !visible {
+		log.Info("post hidden from viewer", slog.String("visibility", userPost.Visibility))
+
+		return models.PostUser{}, fmt.Errorf("%s: %w", op, storage.ErrPostNotFound)
+	}
+	if err := readable(userPost); err != nil {
+		return models.PostUser{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return userPost, nil
+}
+
+func readable(post models.PostUser) error {
+	// Истёкший пост может ещё не быть удалён reaper'ом
+	if post.Expired(time.Now()) {
+		return ErrPostExpired
+	}
+	if post.ConsumedAt != nil {
+		return ErrPostConsumed
+	}
+	return nil
+}
+
+// canView applies the post's visibility to viewer. Unknown values are treated as private.
+func (s *Service) canView(ctx context.Context, viewer string, post models.PostUser) (bool, error) {
+	switch post.Visibility {
+	case models.VisibilityPublic, models.VisibilityUnlisted:
+		return true, nil
+	case models.VisibilityFollowers:
+		if viewer == post.Email {
+			return true, nil
+		}
+		return s.dbFollows.IsFollowerDB(ctx, viewer, post.Email)
+	default:
+		return viewer == post.Email, nil
+	}
+}
+
+// GetAll returns a page of posts matching filter and the AfterID of the next page, zero when there is none.
+// Only public posts and the viewer's own posts are listed.
+func (s *Service) GetAll(ctx context.Context, viewer string, filter models.PostFilter) ([]models.PostUser, int64, error) {
 	const op = "service.GetAll"
 
 	log := s.log.With(
@@ -139,34 +310,43 @@ func (s *Service) GetAll(ctx context.Context) ([]models.PostUser, error) {
 
 	log.Info("getting all")
 
-	userPost, err := s.dbAllGetter.GetAllDB(ctx)
+	// Берём на одну запись больше, чтобы понять, есть ли следующая страница
+	limit := filter.Limit
+	filter.Limit++
+	filter.Viewer = viewer
+
+	userPost, err := s.dbAllGetter.GetAllDB(ctx, filter)
 	if err != nil {
 		log.Error("error while getting all", sl.Err(err))
 
-		return []models.PostUser{}, fmt.Errorf("%s: %w", op, err)
+		return nil, 0, fmt.Errorf("%s: %w", op, err)
 	}
-	if len(userPost) == 0 {
-		return []models.PostUser{}, fmt.Errorf("%s: %w", op, ErrNoFollowers)
+
+	var next int64
+	if len(userPost) > limit {
+		userPost = userPost[:limit]
+		next = userPost[len(userPost)-1].ID
 	}
-	return userPost, nil
+	return userPost, next, nil
 }
 
-func (s *Service) Delete(ctx context.Context, ids []int) ([]string, error) {
-	const op = "service.GetById"
+func (s *Service) Delete(ctx context.Context, email string, isAdmin bool, ids []int) ([]models.DeleteResult, error) {
+	const op = "service.Delete"
 
 	log := s.log.With(
 		slog.String("op", op),
+		slog.String("user", email),
 	)
 
 	log.Info("deleting by id")
 
-	delObjects, err := s.dbDeleter.DeleteDB(ctx, ids)
+	results, err := s.dbDeleter.DeleteDB(ctx, email, isAdmin, ids)
 	if err != nil {
-		log.Error("error while getting by id", sl.Err(err))
+		log.Error("error while deleting by id", sl.Err(err))
 
-		return []string{}, fmt.Errorf("%s: %w", op, err)
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
-	return delObjects, nil
+	return results, nil
 }
 
 func (s *Service) WhoSubbed(ctx context.Context, email string) ([]int, error) {
@@ -191,3 +371,99 @@ func (s *Service) WhoSubbed(ctx context.Context, email string) ([]int, error) {
 	}
 	return subbs, nil
 }
+
+// FanOut materializes a post-created event into the feeds of the author's followers.
+func (s *Service) FanOut(ctx context.Context, post models.Post) error {
+	const op = "service.FanOut"
+
+	log := s.log.With(
+		slog.String("op", op),
+		slog.Int("post_id", post.PostID),
+	)
+
+	followers, err := s.WhoSubbed(ctx, post.Email)
+	if err != nil && !errors.Is(err, ErrNoFollowers) {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if len(followers) == 0 {
+		log.Debug("author has no followers")
+
+		return nil
+	}
+
+	if err := s.dbFeed.AddToFeedDB(ctx, post, followers); err != nil {
+		log.Error("error while adding to feeds", sl.Err(err))
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("post added to feeds", slog.Int("followers", len(followers)))
+
+	return nil
+}
+
+// Feed returns a page of the user's feed and the cursor of the next page, zero when there is none.
+func (s *Service) Feed(ctx context.Context, email string, cursor int64, limit int) ([]models.FeedItem, int64, error) {
+	const op = "service.Feed"
+
+	log := s.log.With(
+		slog.String("op", op),
+		slog.String("user", email),
+	)
+
+	userID, err := s.dbUserGetter.UserIDByEmailDB(ctx, email)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("user not found", sl.Err(err))
+
+			return nil, 0, fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+		return nil, 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Берём на одну запись больше, чтобы понять, есть ли следующая страница
+	items, err := s.dbFeed.FeedDB(ctx, userID, cursor, limit+1)
+	if err != nil {
+		log.Error("error while getting feed", sl.Err(err))
+
+		return nil, 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var next int64
+	if len(items) > limit {
+		items = items[:limit]
+		next = items[len(items)-1].ID
+	}
+
+	return items, next, nil
+}
+
+// Consume claims the single read of a burn-after-read post. The returned release
+// function gives the read back and must be called if the download then fails.
+func (s *Service) Consume(ctx context.Context, id int) (func(), error) {
+	const op = "service.Consume"
+
+	log := s.log.With(
+		slog.String("op", op),
+		slog.Int("post_id", id),
+	)
+
+	consumedAt, err := s.dbBurner.ConsumeDB(ctx, id)
+	if err != nil {
+		if errors.Is(err, storage.ErrPostConsumed) {
+			log.Info("post already read")
+
+			return nil, fmt.Errorf("%s: %w", op, ErrPostConsumed)
+		}
+		log.Error("error while consuming post", sl.Err(err))
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	release := func() {
+		if err := s.dbBurner.ReleaseConsumeDB(context.WithoutCancel(ctx), id, consumedAt); err != nil {
+			log.Error("error while releasing post", sl.Err(err))
+		}
+	}
+	return release, nil
+}
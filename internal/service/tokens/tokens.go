@@ -0,0 +1,179 @@
+package tokens
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"github.com/maestro-milagro/Post_Service_PB/internal/lib/auth"
+	"github.com/maestro-milagro/Post_Service_PB/internal/lib/sl"
+	"github.com/maestro-milagro/Post_Service_PB/internal/lib/slug"
+	"github.com/maestro-milagro/Post_Service_PB/internal/models"
+	"github.com/maestro-milagro/Post_Service_PB/internal/storage"
+	"log/slog"
+	"slices"
+	"time"
+	"unicode/utf8"
+)
+
+// Prefix starts every API token, which tells them apart from JWTs and makes leaked ones easy to scan for.
+const Prefix = "pbt_"
+
+const (
+	// secretLength base62 characters give about 238 bits of entropy, so a plain
+	// SHA-256 of the token is enough to store it.
+	secretLength  = 40
+	shownLength   = 6
+	maxNameLength = 100
+	// touchInterval is how often a use of a token is recorded, see TouchAPITokenDB.
+	touchInterval = time.Minute
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid api token")
+	ErrNoToken      = errors.New("api token not found")
+	ErrInvalidScope = errors.New("invalid scope")
+	ErrInvalidName  = errors.New("token name must be 1 to 100 characters")
+)
+
+type Store interface {
+	CreateAPITokenDB(ctx context.Context, token models.APIToken, hash []byte) (models.APIToken, error)
+	APITokensDB(ctx context.Context, email string) ([]models.APIToken, error)
+	APITokenByHashDB(ctx context.Context, hash []byte) (models.APIToken, error)
+	RevokeAPITokenDB(ctx context.Context, email string, id int64) error
+	TouchAPITokenDB(ctx context.Context, id int64) error
+}
+
+// Service issues personal API tokens and authenticates requests made with them.
+type Service struct {
+	log   *slog.Logger
+	store Store
+}
+
+func New(log *slog.Logger, store Store) *Service {
+	return &Service{
+		log:   log.With(slog.String("component", "tokens.Service")),
+		store: store,
+	}
+}
+
+// Create issues a token for email. The returned secret is the token itself;
+// only its hash is stored, so it cannot be shown again.
+func (s *Service) Create(ctx context.Context, email string, name string, scopes []string, expiresAt *time.Time) (models.APIToken, string, error) {
+	const op = "service.tokens.Create"
+
+	if name == "" || utf8.RuneCountInString(name) > maxNameLength {
+		return models.APIToken{}, "", fmt.Errorf("%s: %w", op, ErrInvalidName)
+	}
+
+	scopes, err := normalize(scopes)
+	if err != nil {
+		return models.APIToken{}, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	random, err := slug.New(secretLength)
+	if err != nil {
+		return models.APIToken{}, "", fmt.Errorf("%s: %w", op, err)
+	}
+	secret := Prefix + random
+
+	token, err := s.store.CreateAPITokenDB(ctx, models.APIToken{
+		Email:     email,
+		Name:      name,
+		Prefix:    secret[:len(Prefix)+shownLength],
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+	}, hash(secret))
+	if err != nil {
+		return models.APIToken{}, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.log.Info("api token created", slog.String("user", email), slog.Int64("token_id", token.ID))
+
+	return token, secret, nil
+}
+
+func (s *Service) List(ctx context.Context, email string) ([]models.APIToken, error) {
+	const op = "service.tokens.List"
+
+	tokens, err := s.store.APITokensDB(ctx, email)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return tokens, nil
+}
+
+func (s *Service) Revoke(ctx context.Context, email string, id int64) error {
+	const op = "service.tokens.Revoke"
+
+	if err := s.store.RevokeAPITokenDB(ctx, email, id); err != nil {
+		if errors.Is(err, storage.ErrNoToken) {
+			return fmt.Errorf("%s: %w", op, ErrNoToken)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.log.Info("api token revoked", slog.String("user", email), slog.Int64("token_id", id))
+
+	return nil
+}
+
+// Authenticate implements auth.Authenticator for API tokens.
+func (s *Service) Authenticate(ctx context.Context, secret string) (auth.Principal, error) {
+	const op = "service.tokens.Authenticate"
+
+	token, err := s.store.APITokenByHashDB(ctx, hash(secret))
+	if err != nil {
+		if errors.Is(err, storage.ErrNoToken) {
+			return auth.Principal{}, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+		}
+		return auth.Principal{}, fmt.Errorf("%s: %w", op, err)
+	}
+	now := time.Now()
+	if !token.Active(now) {
+		return auth.Principal{}, fmt.Errorf("%s: %w: revoked or expired", op, ErrInvalidToken)
+	}
+
+	// Недавнее использование уже записано, лишний UPDATE не нужен; одновременные запросы отсекает сам UPDATE
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) >= touchInterval {
+		// Отметка об использовании не должна мешать запросу
+		if err := s.store.TouchAPITokenDB(ctx, token.ID); err != nil {
+			s.log.Warn("failed to record token use", sl.Err(err), slog.Int64("token_id", token.ID))
+		}
+	}
+
+	scopes := token.Scopes
+	if scopes == nil {
+		// nil в Principal означает «без ограничений», токену такое не положено
+		scopes = []string{}
+	}
+
+	return auth.Principal{
+		Email:   token.Email,
+		TokenID: token.ID,
+		Scopes:  scopes,
+	}, nil
+}
+
+// normalize validates scopes and returns them sorted without duplicates.
+func normalize(scopes []string) ([]string, error) {
+	if len(scopes) == 0 {
+		return nil, fmt.Errorf("%w: at least one scope is required", ErrInvalidScope)
+	}
+
+	out := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if !models.ValidScope(scope) {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidScope, scope)
+		}
+		out = append(out, scope)
+	}
+	slices.Sort(out)
+
+	return slices.Compact(out), nil
+}
+
+func hash(secret string) []byte {
+	sum := sha256.Sum256([]byte(secret))
+	return sum[:]
+}
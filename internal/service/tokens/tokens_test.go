@@ -0,0 +1,267 @@
+package tokens
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/maestro-milagro/Post_Service_PB/internal/models"
+	"github.com/maestro-milagro/Post_Service_PB/internal/storage"
+)
+
+// store keeps tokens by hash, as the api_tokens table does, and counts the touches.
+type store struct {
+	tokens  map[string]models.APIToken
+	touched map[int64]int
+	// touchErr, when set, fails every TouchAPITokenDB
+	touchErr error
+}
+
+func newStore() *store {
+	return &store{tokens: map[string]models.APIToken{}, touched: map[int64]int{}}
+}
+
+func (s *store) CreateAPITokenDB(_ context.Context, token models.APIToken, hash []byte) (models.APIToken, error) {
+	token.ID = int64(len(s.tokens) + 1)
+	token.CreatedAt = time.Now()
+	s.tokens[string(hash)] = token
+	return token, nil
+}
+
+func (s *store) APITokensDB(_ context.Context, email string) ([]models.APIToken, error) {
+	var out []models.APIToken
+	for _, t := range s.tokens {
+		if t.Email == email && t.RevokedAt == nil {
+			out = append(out, t)
+		}
+	}
+	return out, nil
+}
+
+func (s *store) APITokenByHashDB(_ context.Context, hash []byte) (models.APIToken, error) {
+	t, ok := s.tokens[string(hash)]
+	if !ok {
+		return models.APIToken{}, storage.ErrNoToken
+	}
+	return t, nil
+}
+
+func (s *store) RevokeAPITokenDB(_ context.Context, email string, id int64) error {
+	for hash, t := range s.tokens {
+		if t.ID == id && t.Email == email {
+			now := time.Now()
+			t.RevokedAt = &now
+			s.tokens[hash] = t
+			return nil
+		}
+	}
+	return storage.ErrNoToken
+}
+
+func (s *store) TouchAPITokenDB(_ context.Context, id int64) error {
+	if s.touchErr != nil {
+		return s.touchErr
+	}
+	s.touched[id]++
+	for hash, t := range s.tokens {
+		if t.ID == id {
+			now := time.Now()
+			t.LastUsedAt = &now
+			s.tokens[hash] = t
+		}
+	}
+	return nil
+}
+
+// set changes the stored token with the given id.
+func (s *store) set(id int64, change func(t *models.APIToken)) {
+	for hash, t := range s.tokens {
+		if t.ID == id {
+			change(&t)
+			s.tokens[hash] = t
+		}
+	}
+}
+
+func newService() (*Service, *store) {
+	st := newStore()
+	return New(slog.New(slog.NewTextHandler(io.Discard, nil)), st), st
+}
+
+func TestCreate(t *testing.T) {
+	s, st := newService()
+	ctx := context.Background()
+
+	token, secret, err := s.Create(ctx, "user@example.com", "ci", []string{models.ScopeWrite, models.ScopeRead, models.ScopeWrite}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(secret, Prefix) || len(secret) != len(Prefix)+secretLength {
+		t.Errorf("secret %q: want %q and %d random characters", secret, Prefix, secretLength)
+	}
+	if token.Prefix != secret[:len(Prefix)+shownLength] {
+		t.Errorf("shown prefix %q of %q", token.Prefix, secret)
+	}
+	if got := strings.Join(token.Scopes, ","); got != "read,write" {
+		t.Errorf("scopes %q, want sorted without duplicates", got)
+	}
+
+	// Хранится только SHA-256 токена, сам он нигде не сохраняется
+	sum := sha256.Sum256([]byte(secret))
+	stored, ok := st.tokens[string(sum[:])]
+	if !ok || len(st.tokens) != 1 {
+		t.Fatal("token is not stored by its SHA-256")
+	}
+	if strings.Contains(stored.Prefix+stored.Name, secret[len(Prefix)+shownLength:]) {
+		t.Error("stored token reveals more than the shown prefix")
+	}
+
+	_, other, err := s.Create(ctx, "user@example.com", "ci", []string{models.ScopeRead}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == secret {
+		t.Error("two tokens share a secret")
+	}
+
+	invalid := []struct {
+		name   string
+		scopes []string
+		want   error
+	}{
+		{"", []string{models.ScopeRead}, ErrInvalidName},
+		{strings.Repeat("n", maxNameLength+1), []string{models.ScopeRead}, ErrInvalidName},
+		{"ci", nil, ErrInvalidScope},
+		{"ci", []string{"admin"}, ErrInvalidScope},
+	}
+	for _, tt := range invalid {
+		if _, _, err := s.Create(ctx, "user@example.com", tt.name, tt.scopes, nil); !errors.Is(err, tt.want) {
+			t.Errorf("name %q, scopes %v: got %v, want %v", tt.name, tt.scopes, err, tt.want)
+		}
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	s, st := newService()
+	ctx := context.Background()
+
+	create := func(scopes []string, expiresAt *time.Time) (models.APIToken, string) {
+		t.Helper()
+
+		token, secret, err := s.Create(ctx, "user@example.com", "ci", scopes, expiresAt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token, secret
+	}
+
+	readOnly, readSecret := create([]string{models.ScopeRead}, nil)
+
+	principal, err := s.Authenticate(ctx, readSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if principal.Email != "user@example.com" || principal.TokenID != readOnly.ID {
+		t.Errorf("principal %+v", principal)
+	}
+	if !principal.Allows(models.ScopeRead) || principal.Allows(models.ScopeWrite) || principal.Allows(models.ScopeDelete) {
+		t.Errorf("read token allows %v", principal.Scopes)
+	}
+	if principal.IsAdmin() {
+		t.Error("api token carries a role")
+	}
+
+	// Токен без scopes не должен превращаться в Principal без ограничений
+	st.set(readOnly.ID, func(t *models.APIToken) { t.Scopes = nil })
+	if principal, err := s.Authenticate(ctx, readSecret); err != nil || principal.Scopes == nil || principal.Allows(models.ScopeRead) {
+		t.Errorf("token without scopes: %+v, %v", principal, err)
+	}
+
+	past := time.Now().Add(-time.Hour)
+	_, expiredSecret := create([]string{models.ScopeRead}, &past)
+
+	unknown := Prefix + strings.Repeat("x", secretLength)
+
+	for name, secret := range map[string]string{
+		"expired":     expiredSecret,
+		"unknown":     unknown,
+		"without pbt": readSecret[len(Prefix):],
+	} {
+		if _, err := s.Authenticate(ctx, secret); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s: got %v, want ErrInvalidToken", name, err)
+		}
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	s, _ := newService()
+	ctx := context.Background()
+
+	token, secret, err := s.Create(ctx, "user@example.com", "ci", []string{models.ScopeRead}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Revoke(ctx, "other@example.com", token.ID); !errors.Is(err, ErrNoToken) {
+		t.Errorf("revoke by another user: got %v, want ErrNoToken", err)
+	}
+	if _, err := s.Authenticate(ctx, secret); err != nil {
+		t.Fatalf("token revoked by another user: %v", err)
+	}
+
+	if err := s.Revoke(ctx, "user@example.com", token.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Authenticate(ctx, secret); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("revoked token: got %v, want ErrInvalidToken", err)
+	}
+	if tokens, _ := s.List(ctx, "user@example.com"); len(tokens) != 0 {
+		t.Errorf("revoked token listed: %+v", tokens)
+	}
+	if err := s.Revoke(ctx, "user@example.com", token.ID+1); !errors.Is(err, ErrNoToken) {
+		t.Errorf("revoke unknown token: got %v, want ErrNoToken", err)
+	}
+}
+
+func TestAuthenticateThrottlesTouch(t *testing.T) {
+	s, st := newService()
+	ctx := context.Background()
+
+	token, secret, err := s.Create(ctx, "user@example.com", "ci", []string{models.ScopeRead}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := s.Authenticate(ctx, secret); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if st.touched[token.ID] != 1 {
+		t.Fatalf("touched %d times within %s, want 1", st.touched[token.ID], touchInterval)
+	}
+
+	st.set(token.ID, func(t *models.APIToken) {
+		stale := time.Now().Add(-touchInterval - time.Second)
+		t.LastUsedAt = &stale
+	})
+	if _, err := s.Authenticate(ctx, secret); err != nil {
+		t.Fatal(err)
+	}
+	if st.touched[token.ID] != 2 {
+		t.Errorf("touched %d times after %s, want 2", st.touched[token.ID], touchInterval)
+	}
+
+	// Ошибка записи last_used_at не мешает запросу
+	st.touchErr = errors.New("database is down")
+	st.set(token.ID, func(t *models.APIToken) { t.LastUsedAt = nil })
+	if _, err := s.Authenticate(ctx, secret); err != nil {
+		t.Errorf("failed touch rejected the token: %v", err)
+	}
+}
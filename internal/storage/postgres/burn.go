@@ -0,0 +1,45 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/maestro-milagro/Post_Service_PB/internal/storage"
+	"time"
+)
+
+// ConsumeDB claims a burn-after-read post for one reader. The conditional update
+// makes the claim atomic: of two concurrent readers exactly one gets the row back.
+func (s *Storage) ConsumeDB(ctx context.Context, id int) (time.Time, error) {
+	const op = "Storage/postgres/ConsumeDB"
+
+	var consumedAt time.Time
+
+	err := s.db.QueryRowContext(ctx, `
+		UPDATE users_posts SET consumed_at = now()
+		WHERE id = $1 AND burn_after_read AND consumed_at IS NULL
+		AND (expires_at IS NULL OR expires_at > now())
+		RETURNING consumed_at`, id).Scan(&consumedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return time.Time{}, fmt.Errorf("%s: %w", op, storage.ErrPostConsumed)
+		}
+		return time.Time{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return consumedAt, nil
+}
+
+// ReleaseConsumeDB undoes a claim whose download failed, so the post can still be read once.
+func (s *Storage) ReleaseConsumeDB(ctx context.Context, id int, consumedAt time.Time) error {
+	const op = "Storage/postgres/ReleaseConsumeDB"
+
+	_, err := s.db.ExecContext(ctx,
+		"UPDATE users_posts SET consumed_at = NULL WHERE id = $1 AND consumed_at = $2", id, consumedAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,86 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"github.com/maestro-milagro/Post_Service_PB/internal/models"
+	"github.com/maestro-milagro/Post_Service_PB/internal/storage"
+)
+
+// StaleKeyPostsDB returns encrypted posts whose data key is wrapped by a master key other than activeKeyID, in id order.
+func (s *Storage) StaleKeyPostsDB(ctx context.Context, activeKeyID string, afterID int64, limit int) ([]models.PostUser, error) {
+	const op = "Storage/postgres/StaleKeyPostsDB"
+
+	posts := []models.PostUser{}
+
+	err := s.db.SelectContext(ctx, &posts,
+		"SELECT "+postColumns+" FROM users_posts WHERE id > $1 AND enc_key_id <> '' AND enc_key_id <> $2 ORDER BY id LIMIT $3",
+		afterID, activeKeyID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return posts, nil
+}
+
+// UpdateDataKeyDB stores the rewrapped data key only if the post is still wrapped by oldKeyID.
+func (s *Storage) UpdateDataKeyDB(ctx context.Context, id int64, oldKeyID string, newKeyID string, dataKey []byte) error {
+	const op = "Storage/postgres/UpdateDataKeyDB"
+
+	res, err := s.db.ExecContext(ctx,
+		"UPDATE users_posts SET enc_key_id = $3, enc_data_key = $4 WHERE id = $1 AND enc_key_id = $2",
+		id, oldKeyID, newKeyID, dataKey)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrPostNotFound)
+	}
+
+	return nil
+}
+
+// StaleKeyRevisionsDB is StaleKeyPostsDB for the revision history, in (post_id, revision) order.
+func (s *Storage) StaleKeyRevisionsDB(ctx context.Context, activeKeyID string, after models.PostRevision, limit int) ([]models.PostRevision, error) {
+	const op = "Storage/postgres/StaleKeyRevisionsDB"
+
+	revs := []models.PostRevision{}
+
+	err := s.db.SelectContext(ctx, &revs,
+		"SELECT "+revisionColumns+` FROM post_revisions
+		WHERE (post_id, revision) > ($1, $2) AND enc_key_id <> '' AND enc_key_id <> $3
+		ORDER BY post_id, revision LIMIT $4`,
+		after.PostID, after.Revision, activeKeyID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return revs, nil
+}
+
+// UpdateRevisionDataKeyDB stores the rewrapped data key only if the revision is still wrapped by oldKeyID.
+func (s *Storage) UpdateRevisionDataKeyDB(ctx context.Context, rev models.PostRevision, newKeyID string, dataKey []byte) error {
+	const op = "Storage/postgres/UpdateRevisionDataKeyDB"
+
+	res, err := s.db.ExecContext(ctx,
+		"UPDATE post_revisions SET enc_key_id = $4, enc_data_key = $5 WHERE post_id = $1 AND revision = $2 AND enc_key_id = $3",
+		rev.PostID, rev.Revision, rev.EncKeyID, newKeyID, dataKey)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrNoRevision)
+	}
+
+	return nil
+}
@@ -0,0 +1,41 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"github.com/lib/pq"
+	"github.com/maestro-milagro/Post_Service_PB/internal/models"
+	"time"
+)
+
+// ExpiredPostsDB returns up to limit posts that expired at or before now, oldest expiration first.
+func (s *Storage) ExpiredPostsDB(ctx context.Context, now time.Time, limit int) ([]models.PostUser, error) {
+	const op = "Storage/postgres/ExpiredPostsDB"
+
+	posts := []models.PostUser{}
+
+	err := s.db.SelectContext(ctx, &posts,
+		"SELECT "+postColumns+" FROM users_posts WHERE expires_at <= $1 ORDER BY expires_at LIMIT $2",
+		now, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return posts, nil
+}
+
+func (s *Storage) DeletePostsDB(ctx context.Context, ids []int64) (int64, error) {
+	const op = "Storage/postgres/DeletePostsDB"
+
+	res, err := s.db.ExecContext(ctx, "DELETE FROM users_posts WHERE id = ANY($1)", pq.Array(ids))
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return n, nil
+}
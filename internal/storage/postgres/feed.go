@@ -0,0 +1,89 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"github.com/maestro-milagro/Post_Service_PB/internal/models"
+	"github.com/maestro-milagro/Post_Service_PB/internal/storage"
+)
+
+// AddToFeedDB puts the post into every follower's feed. Re-delivered events are
+// no-ops thanks to the (user_id, post_id) key; posts deleted, expired or already
+// read in the meantime are skipped, and so are unlisted and private ones, which
+// are never listed.
+func (s *Storage) AddToFeedDB(ctx context.Context, post models.Post, followerIDs []int) error {
+	const op = "Storage/postgres/AddToFeedDB"
+
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO feeds (user_id, post_id, author)
+		SELECT f.user_id, p.id, p.email
+		FROM unnest($1::integer[]) AS f(user_id)
+		JOIN users_posts AS p ON p.id = $2 AND p.visibility IN ('public', 'followers')
+			AND (p.expires_at IS NULL OR p.expires_at > now()) AND p.consumed_at IS NULL
+		ON CONFLICT (user_id, post_id) DO NOTHING`, pq.Array(followerIDs), post.PostID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// FeedDB returns up to limit feed entries of the user older than cursor, newest first.
+// A zero cursor starts from the newest entry. Posts made unlisted or private since
+// they were added are left out, and so are expired ones the reaper has not deleted yet
+// and burn-after-read ones that have been read.
+func (s *Storage) FeedDB(ctx context.Context, userID int, cursor int64, limit int) ([]models.FeedItem, error) {
+	const op = "Storage/postgres/FeedDB"
+
+	items := []models.FeedItem{}
+
+	err := s.db.SelectContext(ctx, &items, `
+		SELECT f.id, f.post_id, p.slug, f.author, f.created_at FROM feeds AS f
+		JOIN users_posts AS p ON p.id = f.post_id AND p.visibility IN ('public', 'followers')
+			AND (p.expires_at IS NULL OR p.expires_at > now()) AND p.consumed_at IS NULL
+		WHERE f.user_id = $1 AND ($2::bigint = 0 OR f.id < $2::bigint)
+		ORDER BY f.id DESC
+		LIMIT $3`, userID, cursor, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return items, nil
+}
+
+func (s *Storage) UserIDByEmailDB(ctx context.Context, email string) (int, error) {
+	const op = "Storage/postgres/UserIDByEmailDB"
+
+	var id int
+	if err := s.db.QueryRowContext(ctx, "SELECT id FROM users WHERE email = $1", email).Scan(&id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+		}
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// IsFollowerDB tells whether follower is subscribed to author.
+func (s *Storage) IsFollowerDB(ctx context.Context, followerEmail string, authorEmail string) (bool, error) {
+	const op = "Storage/postgres/IsFollowerDB"
+
+	var ok bool
+
+	err := s.db.GetContext(ctx, &ok, `
+		SELECT EXISTS (
+			SELECT 1 FROM subscriptions AS s
+			JOIN users AS a ON a.id = s.uid
+			JOIN users AS f ON f.id = s.sub_id
+			WHERE a.email = $1 AND f.email = $2
+		)`, authorEmail, followerEmail)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return ok, nil
+}
@@ -0,0 +1,42 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"github.com/maestro-milagro/Post_Service_PB/internal/models"
+)
+
+// AncestorsDB returns the posts the post was forked from, nearest parent first,
+// at most depth of them. Deleted ancestors end the chain.
+func (s *Storage) AncestorsDB(ctx context.Context, id int64, depth int) ([]models.PostUser, error) {
+	const op = "Storage/postgres/AncestorsDB"
+
+	posts := []models.PostUser{}
+
+	err := s.db.SelectContext(ctx, &posts, `
+		WITH RECURSIVE chain (id, depth) AS (
+			SELECT parent_id, 1 FROM users_posts WHERE id = $1 AND parent_id IS NOT NULL
+			UNION ALL
+			SELECT p.parent_id, c.depth + 1 FROM chain AS c JOIN users_posts AS p ON p.id = c.id
+			WHERE p.parent_id IS NOT NULL AND c.depth < $2
+		)
+		SELECT `+postColumns+` FROM users_posts JOIN chain USING (id) ORDER BY depth`, id, depth)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return posts, nil
+}
+
+// ForkCountDB returns the number of direct forks of the post.
+func (s *Storage) ForkCountDB(ctx context.Context, id int64) (int, error) {
+	const op = "Storage/postgres/ForkCountDB"
+
+	var forks int
+
+	if err := s.db.GetContext(ctx, &forks, "SELECT count(*) FROM users_posts WHERE parent_id = $1", id); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return forks, nil
+}
@@ -0,0 +1,76 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"github.com/maestro-milagro/Post_Service_PB/internal/models"
+	"github.com/maestro-milagro/Post_Service_PB/internal/storage"
+)
+
+// LegacyKeyPostsDB returns posts still stored under filename-derived keys, in id order.
+func (s *Storage) LegacyKeyPostsDB(ctx context.Context, prefix string, afterID int64, limit int) ([]models.PostUser, error) {
+	const op = "Storage/postgres/LegacyKeyPostsDB"
+
+	posts := []models.PostUser{}
+
+	err := s.db.SelectContext(ctx, &posts,
+		"SELECT "+postColumns+" FROM users_posts WHERE id > $1 AND NOT starts_with(key, $2) ORDER BY id LIMIT $3",
+		afterID, prefix, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return posts, nil
+}
+
+// UpdatePostKeyDB moves the post, and its revisions stored under the same object, to newKey
+// only if the post still points at oldKey.
+func (s *Storage) UpdatePostKeyDB(ctx context.Context, id int64, oldKey string, newKey string) error {
+	const op = "Storage/postgres/UpdatePostKeyDB"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, "UPDATE users_posts SET key = $3 WHERE id = $1 AND key = $2", id, oldKey, newKey)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrPostNotFound)
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE post_revisions SET key = $3 WHERE post_id = $1 AND key = $2", id, oldKey, newKey)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// CountKeyRefsDB tells how many posts and revisions still reference the object.
+func (s *Storage) CountKeyRefsDB(ctx context.Context, bucket string, key string) (int, error) {
+	const op = "Storage/postgres/CountKeyRefsDB"
+
+	var n int
+	err := s.db.GetContext(ctx, &n, `
+		SELECT (SELECT count(*) FROM users_posts WHERE bucket = $1 AND key = $2)
+		     + (SELECT count(*) FROM post_revisions AS r JOIN users_posts AS p ON p.id = r.post_id
+		        WHERE p.bucket = $1 AND r.key = $2)`, bucket, key)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return n, nil
+}
@@ -0,0 +1,119 @@
+package migrator
+
+import (
+	"errors"
+	"testing"
+	"testing/fstest"
+
+	"github.com/maestro-milagro/Post_Service_PB/migrations"
+)
+
+func file(body string) *fstest.MapFile {
+	return &fstest.MapFile{Data: []byte(body)}
+}
+
+func TestLoad(t *testing.T) {
+	source := fstest.MapFS{
+		"10_ten.up.sql":   file("UP 10"),
+		"2_two.up.sql":    file("UP 2"),
+		"2_two.down.sql":  file("DOWN 2"),
+		"1_one.up.sql":    file("UP 1"),
+		"1_one.down.sql":  file("DOWN 1"),
+		"README.md":       file("not a migration"),
+		"sub/3_x.up.sql":  file("in a directory"),
+		"10_ten.down.sql": file("DOWN 10"),
+	}
+
+	got, err := load(source)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	want := []Migration{
+		{Version: 1, Name: "one", Up: "UP 1", Down: "DOWN 1"},
+		{Version: 2, Name: "two", Up: "UP 2", Down: "DOWN 2"},
+		// Версии сравниваются как числа, а не как строки
+		{Version: 10, Name: "ten", Up: "UP 10", Down: "DOWN 10"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("loaded %d migrations, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("migration %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		source fstest.MapFS
+		want   error
+	}{
+		{
+			name: "two names for one version",
+			source: fstest.MapFS{
+				"1_one.up.sql":   file("UP"),
+				"1_other.up.sql": file("UP"),
+			},
+			want: ErrDuplicateFile,
+		},
+		{
+			name: "same version written differently",
+			source: fstest.MapFS{
+				"1_one.up.sql":  file("UP"),
+				"01_one.up.sql": file("UP"),
+			},
+			want: ErrDuplicateFile,
+		},
+		{
+			name: "down without up",
+			source: fstest.MapFS{
+				"1_one.down.sql": file("DOWN"),
+			},
+			want: ErrBadFileName,
+		},
+		{
+			name: "misnamed file",
+			source: fstest.MapFS{
+				"1_one.up.sql": file("UP"),
+				"2-two.up.sql": file("UP"),
+			},
+			want: ErrBadFileName,
+		},
+		{
+			name: "version out of range",
+			source: fstest.MapFS{
+				"99999999999999999999_big.up.sql": file("UP"),
+			},
+			want: ErrBadFileName,
+		},
+	}
+
+	for _, tt := range tests {
+		if _, err := load(tt.source); !errors.Is(err, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	got, err := load(migrations.FS)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(got) == 0 {
+		t.Fatal("no migrations embedded")
+	}
+
+	// Версии идут подряд, и каждую миграцию можно откатить
+	for i, mig := range got {
+		if mig.Version != int64(i+1) {
+			t.Errorf("migration %d_%s, want version %d", mig.Version, mig.Name, i+1)
+		}
+		if mig.Down == "" {
+			t.Errorf("migration %d_%s has no down script", mig.Version, mig.Name)
+		}
+	}
+}
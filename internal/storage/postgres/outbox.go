@@ -0,0 +1,131 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/maestro-milagro/Post_Service_PB/internal/models"
+	"sort"
+	"time"
+)
+
+func insertOutbox(ctx context.Context, tx *sql.Tx, topic string, key string, event any) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO outbox (topic, event_key, payload) VALUES ($1, $2, $3)", topic, key, payload)
+
+	return err
+}
+
+// outboxClaimLock is the pg_advisory_xact_lock key serializing claims of relays.
+const outboxClaimLock = 7_130_424_119
+
+// ClaimOutboxDB leases up to limit due events to the caller. A claimed event is hidden
+// from other relays until the lease runs out, so an instance that dies mid-publish
+// only delays delivery instead of losing it. Events whose key has an earlier event
+// still leased or backing off are left alone to keep per-key order.
+func (s *Storage) ClaimOutboxDB(ctx context.Context, limit int, lease time.Duration) ([]models.OutboxEvent, error) {
+	const op = "Storage/postgres/ClaimOutboxDB"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	// Без блокировки проверка NOT EXISTS видела бы снимок до того, как другой relay
+	// закоммитил аренду предыдущего события ключа, и следующее ушло бы раньше него
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", outboxClaimLock); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		UPDATE outbox SET next_attempt_at = now() + $2 * interval '1 millisecond'
+		WHERE id IN (
+			SELECT o.id FROM outbox AS o
+			WHERE o.delivered_at IS NULL AND o.next_attempt_at <= now()
+			AND NOT EXISTS (
+				SELECT 1 FROM outbox AS p
+				WHERE p.event_key = o.event_key AND p.id < o.id
+				AND p.delivered_at IS NULL AND p.next_attempt_at > now()
+			)
+			ORDER BY o.id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, topic, event_key, payload, attempts`, limit, lease.Milliseconds())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var events []models.OutboxEvent
+	for rows.Next() {
+		var event models.OutboxEvent
+		if err := rows.Scan(&event.ID, &event.Topic, &event.Key, &event.Payload, &event.Attempts); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	rows.Close()
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// RETURNING не гарантирует порядок, а события одного автора должны уходить по очереди
+	sort.Slice(events, func(i, j int) bool { return events[i].ID < events[j].ID })
+
+	return events, nil
+}
+
+func (s *Storage) MarkDeliveredDB(ctx context.Context, id int64) error {
+	const op = "Storage/postgres/MarkDeliveredDB"
+
+	if _, err := s.db.ExecContext(ctx, "UPDATE outbox SET delivered_at = now(), last_error = NULL WHERE id = $1", id); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) MarkFailedDB(ctx context.Context, id int64, reason string, retryAt time.Time) error {
+	const op = "Storage/postgres/MarkFailedDB"
+
+	_, err := s.db.ExecContext(ctx,
+		"UPDATE outbox SET attempts = attempts + 1, last_error = $2, next_attempt_at = $3 WHERE id = $1",
+		id, reason, retryAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// PurgeOutboxDB deletes up to limit events delivered before the given time.
+func (s *Storage) PurgeOutboxDB(ctx context.Context, before time.Time, limit int) (int64, error) {
+	const op = "Storage/postgres/PurgeOutboxDB"
+
+	res, err := s.db.ExecContext(ctx, `
+		DELETE FROM outbox WHERE id IN (
+			SELECT id FROM outbox WHERE delivered_at < $1 LIMIT $2
+		)`, before, limit)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return n, nil
+}
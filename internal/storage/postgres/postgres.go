@@ -2,16 +2,20 @@ package postgres
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"github.com/jmoiron/sqlx"
-	"github.com/lib/pq"
 	_ "github.com/lib/pq"
+	"github.com/maestro-milagro/Post_Service_PB/internal/lib/slug"
 	"github.com/maestro-milagro/Post_Service_PB/internal/models"
 	"github.com/maestro-milagro/Post_Service_PB/internal/storage"
+	"strings"
 )
 
 type Storage struct {
-	db *sqlx.DB
+	db         *sqlx.DB
+	slugLength int
 }
 
 type Config struct {
@@ -21,6 +25,9 @@ type Config struct {
 	Password string
 	DBName   string
 	SSLMode  string
+
+	// SlugLength is the length of generated post slugs, slug.DefaultLength when zero.
+	SlugLength int
 }
 
 func New(cfg Config) (*Storage, error) {
@@ -32,109 +39,247 @@ func New(cfg Config) (*Storage, error) {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	return &Storage{db: db}, nil
+	slugLength := cfg.SlugLength
+	if slugLength <= 0 {
+		slugLength = slug.DefaultLength
+	}
+
+	return &Storage{db: db, slugLength: slugLength}, nil
 }
 
-func (s *Storage) SubscribeDB(ctx context.Context, uid int, subId int) error {
-	const op = "storage.postgres.NewSubscribeDB"
+// DB exposes the underlying connection pool for the migrator.
+func (s *Storage) DB() *sql.DB {
+	return s.db.DB
+}
+
+func (s *Storage) Close() error {
+	return s.db.Close()
+}
 
-	tx, err := s.db.Begin()
+// SubscribeDB subscribes user subId to user uid. Subscribing again is not an error;
+// a missing uid is reported as storage.ErrUserNotFound.
+func (s *Storage) SubscribeDB(ctx context.Context, uid int, subId int) error {
+	const op = "Storage/postgres/SubscribeDB"
+
+	var exists bool
+
+	// Автора проверяем в том же запросе, что и вставку: в subscriptions нет внешних ключей
+	err := s.db.GetContext(ctx, &exists, `
+		WITH author AS (SELECT id FROM users WHERE id = $1),
+		     inserted AS (
+		         INSERT INTO subscriptions (uid, sub_id) SELECT id, $2 FROM author
+		         ON CONFLICT (uid, sub_id) DO NOTHING
+		     )
+		SELECT EXISTS (SELECT 1 FROM author)`, uid, subId)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
+	if !exists {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	return nil
+}
 
-	createListQuery := fmt.Sprintf("INSERT INTO subscriptions (uid, sub_id) VALUES ($1, $2)")
+// UnsubscribeDB removes the subscription of subId to uid, if there is one.
+func (s *Storage) UnsubscribeDB(ctx context.Context, uid int, subId int) error {
+	const op = "Storage/postgres/UnsubscribeDB"
 
-	_, err = tx.Exec(createListQuery, uid, subId)
+	_, err := s.db.ExecContext(ctx, "DELETE FROM subscriptions WHERE uid = $1 AND sub_id = $2", uid, subId)
 	if err != nil {
-		switch e := err.(type) {
-		case *pq.Error:
-			switch e.Code {
-			case "23505":
-				// p-key constraint violation
-				tx.Rollback()
-				return fmt.Errorf("%s: %w", op, storage.ErrSubExist)
-			default:
-				tx.Rollback()
-				return fmt.Errorf("%s: %w", op, err)
-			}
-		}
+		return fmt.Errorf("%s: %w", op, err)
 	}
-	return tx.Commit()
+
+	return nil
 }
 
-func (s *Storage) PostSaveDB(ctx context.Context, user models.PostUser) (int64, error) {
+// maxSlugAttempts bounds the retries on slug collisions, which are
+// practically impossible at the default length.
+const maxSlugAttempts = 5
+
+// PostSaveDB stores the post under a fresh random slug together with its post-created
+// outbox event, so the notification is published if and only if the post is committed.
+func (s *Storage) PostSaveDB(ctx context.Context, user models.PostUser) (int64, string, error) {
 	const op = "Storage/postgres/PostSaveDB"
 
-	tx, err := s.db.Begin()
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return 0, fmt.Errorf("%s: %w", op, err)
+		return 0, "", fmt.Errorf("%s: %w", op, err)
 	}
+	defer tx.Rollback()
+
+	// ON CONFLICT не прерывает транзакцию, поэтому при совпадении slug просто пробуем другой
+	createListQuery := `INSERT INTO users_posts (email, bucket, key, title, filename, content_type, size, checksum,
+		expires_at, burn_after_read, visibility, slug, password_hash, enc_key_id, enc_data_key, client_encrypted, parent_id, syntax)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18)
+		ON CONFLICT (slug) DO NOTHING RETURNING id`
 
 	var id int
-	createListQuery := fmt.Sprintf("INSERT INTO users_posts (email, bucket, key) VALUES ($1, $2, $3) RETURNING id")
-	row := tx.QueryRow(createListQuery, user.Email, user.Bucket, user.Key)
-	if err := row.Scan(&id); err != nil {
-		tx.Rollback()
-		return 0, fmt.Errorf("%s: %w", op, err)
+	var postSlug string
+	for attempt := 1; ; attempt++ {
+		postSlug, err = slug.New(s.slugLength)
+		if err != nil {
+			return 0, "", fmt.Errorf("%s: %w", op, err)
+		}
+
+		row := tx.QueryRowContext(ctx, createListQuery,
+			user.Email, user.Bucket, user.Key, user.Title, user.FileName, user.ContentType, user.Size, user.Checksum,
+			user.ExpiresAt, user.BurnAfterRead, user.Visibility, postSlug, user.PasswordHash,
+			user.EncKeyID, user.EncDataKey, user.ClientEncrypted, user.ParentID, user.Syntax)
+		err = row.Scan(&id)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return 0, "", fmt.Errorf("%s: %w", op, err)
+		}
+		if attempt == maxSlugAttempts {
+			return 0, "", fmt.Errorf("%s: no free slug after %d attempts", op, attempt)
+		}
 	}
 
-	return int64(id), tx.Commit()
+	if err := insertRevision(ctx, tx, models.PostRevision{
+		PostID:      int64(id),
+		Revision:    1,
+		Key:         user.Key,
+		FileName:    user.FileName,
+		ContentType: user.ContentType,
+		Size:        user.Size,
+		Checksum:    user.Checksum,
+		EncKeyID:    user.EncKeyID,
+		EncDataKey:  user.EncDataKey,
+		Author:      user.Email,
+	}); err != nil {
+		return 0, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := insertOutbox(ctx, tx, models.PostsTopic, user.Email, models.Post{PostID: id, Email: user.Email}); err != nil {
+		return 0, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return int64(id), postSlug, nil
 }
 
+const postColumns = "id, email, bucket, key, title, filename, content_type, size, checksum, created_at, updated_at, expires_at, burn_after_read, consumed_at, visibility, slug, password_hash, enc_key_id, enc_data_key, client_encrypted, revision, parent_id, syntax"
+
 func (s *Storage) GetByIdDB(ctx context.Context, id int) (models.PostUser, error) {
 	const op = "Storage/postgres/GetByIdDB"
 
 	var user models.PostUser
 
-	createListQuery := fmt.Sprintf("SELECT users_posts.email, users_posts.bucket, users_posts.key FROM users_posts WHERE id = $1")
+	createListQuery := "SELECT " + postColumns + " FROM users_posts WHERE id = $1"
+
+	err := s.db.GetContext(ctx, &user, createListQuery, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.PostUser{}, fmt.Errorf("%s: %w", op, storage.ErrPostNotFound)
+		}
+		return models.PostUser{}, fmt.Errorf("%s: %w", op, err)
+	}
 
-	row := s.db.QueryRow(createListQuery, id)
+	return user, nil
+}
 
-	err := row.Scan(&user.Email, &user.Bucket, &user.Key)
+func (s *Storage) GetBySlugDB(ctx context.Context, slug string) (models.PostUser, error) {
+	const op = "Storage/postgres/GetBySlugDB"
+
+	var user models.PostUser
+
+	createListQuery := "SELECT " + postColumns + " FROM users_posts WHERE slug = $1"
+
+	err := s.db.GetContext(ctx, &user, createListQuery, slug)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.PostUser{}, fmt.Errorf("%s: %w", op, storage.ErrPostNotFound)
+		}
 		return models.PostUser{}, fmt.Errorf("%s: %w", op, err)
 	}
 
 	return user, nil
 }
 
-func (s *Storage) GetAllDB(ctx context.Context) ([]models.PostUser, error) {
+func (s *Storage) GetAllDB(ctx context.Context, filter models.PostFilter) ([]models.PostUser, error) {
 	const op = "Storage/postgres/GetAllDB"
 
-	var users []models.PostUser
+	users := []models.PostUser{}
+
+	conds := []string{"id > $1", "(expires_at IS NULL OR expires_at > now())", "consumed_at IS NULL", "(visibility = 'public' OR email = $2)"}
+	args := []any{filter.AfterID, filter.Viewer}
+
+	if filter.Email != "" {
+		args = append(args, filter.Email)
+		conds = append(conds, fmt.Sprintf("email = $%d", len(args)))
+	}
+	if filter.CreatedFrom != nil {
+		args = append(args, *filter.CreatedFrom)
+		conds = append(conds, fmt.Sprintf("created_at >= $%d", len(args)))
+	}
+	if filter.CreatedTo != nil {
+		args = append(args, *filter.CreatedTo)
+		conds = append(conds, fmt.Sprintf("created_at < $%d", len(args)))
+	}
+	args = append(args, filter.Limit)
 
-	createListQuery := fmt.Sprintf("SELECT users_posts.id, users_posts.email, users_posts.bucket, users_posts.key FROM users_posts")
+	createListQuery := fmt.Sprintf("SELECT %s FROM users_posts WHERE %s ORDER BY id LIMIT $%d",
+		postColumns, strings.Join(conds, " AND "), len(args))
 
-	if err := s.db.Select(&users, createListQuery); err != nil {
+	if err := s.db.SelectContext(ctx, &users, createListQuery, args...); err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
 	return users, nil
 }
 
-func (s *Storage) DeleteDB(ctx context.Context, ids []int) ([]string, error) {
+func (s *Storage) DeleteDB(ctx context.Context, email string, isAdmin bool, ids []int) ([]models.DeleteResult, error) {
 	const op = "Storage/postgres/DeleteDB"
 
-	tx, err := s.db.Begin()
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return []string{}, fmt.Errorf("%s: %w", op, err)
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
+	defer tx.Rollback()
 
-	keys := make([]string, 0, len(ids))
-	var key string
+	results := make([]models.DeleteResult, 0, len(ids))
 
 	for _, id := range ids {
-		createListQuery := fmt.Sprintf("DELETE FROM users_posts WHERE id = $1 RETURNING key")
-		row := tx.QueryRow(createListQuery, id)
-		if err := row.Scan(&key); err != nil {
-			tx.Rollback()
-			return []string{}, fmt.Errorf("%s: %w", op, err)
+		var owner, key string
+
+		row := tx.QueryRowContext(ctx, "SELECT email, key FROM users_posts WHERE id = $1 FOR UPDATE", id)
+		if err := row.Scan(&owner, &key); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				results = append(results, models.DeleteResult{ID: id, Status: models.DeleteStatusNotFound})
+
+				continue
+			}
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		if owner != email && !isAdmin {
+			results = append(results, models.DeleteResult{ID: id, Status: models.DeleteStatusForbidden})
+
+			continue
 		}
-		keys = append(keys, key)
+
+		keys, err := revisionKeys(ctx, tx, id, key)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		if _, err := tx.ExecContext(ctx, "DELETE FROM users_posts WHERE id = $1", id); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		results = append(results, models.DeleteResult{ID: id, Status: models.DeleteStatusDeleted, Keys: keys})
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	return keys, tx.Commit()
+	return results, nil
 }
 
 func (s *Storage) WhoSubbedDB(ctx context.Context, email string) ([]int, error) {
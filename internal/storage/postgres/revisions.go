@@ -0,0 +1,176 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"github.com/maestro-milagro/Post_Service_PB/internal/models"
+	"github.com/maestro-milagro/Post_Service_PB/internal/storage"
+)
+
+const revisionColumns = "post_id, revision, key, filename, content_type, size, checksum, enc_key_id, enc_data_key, author, created_at"
+
+func insertRevision(ctx context.Context, tx *sql.Tx, rev models.PostRevision) error {
+	_, err := tx.ExecContext(ctx, `INSERT INTO post_revisions
+		(post_id, revision, key, filename, content_type, size, checksum, enc_key_id, enc_data_key, author)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		rev.PostID, rev.Revision, rev.Key, rev.FileName, rev.ContentType, rev.Size, rev.Checksum,
+		rev.EncKeyID, rev.EncDataKey, rev.Author)
+	return err
+}
+
+// revisionKeys returns the distinct objects of all revisions of the post, current included.
+func revisionKeys(ctx context.Context, tx *sql.Tx, id int, current string) ([]string, error) {
+	var keys pq.StringArray
+
+	err := tx.QueryRowContext(ctx, `
+		SELECT coalesce(array_agg(DISTINCT key), '{}') FROM post_revisions
+		WHERE post_id = $1 AND key <> $2`, id, current).Scan(&keys)
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]string{current}, keys...), nil
+}
+
+// AddRevisionDB makes rev the current revision of the post owned by email and returns it numbered.
+func (s *Storage) AddRevisionDB(ctx context.Context, email string, rev models.PostRevision) (models.PostRevision, error) {
+	const op = "Storage/postgres/AddRevisionDB"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.PostRevision{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	rev, err = addRevision(ctx, tx, email, rev)
+	if err != nil {
+		return models.PostRevision{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.PostRevision{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return rev, nil
+}
+
+// RestoreRevisionDB adds a new revision of the post with the content of revision n.
+func (s *Storage) RestoreRevisionDB(ctx context.Context, email string, id int64, n int) (models.PostRevision, error) {
+	const op = "Storage/postgres/RestoreRevisionDB"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.PostRevision{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	var rev models.PostRevision
+
+	row := tx.QueryRowContext(ctx, `SELECT key, filename, content_type, size, checksum, enc_key_id, enc_data_key
+		FROM post_revisions WHERE post_id = $1 AND revision = $2`, id, n)
+	err = row.Scan(&rev.Key, &rev.FileName, &rev.ContentType, &rev.Size, &rev.Checksum, &rev.EncKeyID, &rev.EncDataKey)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.PostRevision{}, fmt.Errorf("%s: %w", op, storage.ErrNoRevision)
+		}
+		return models.PostRevision{}, fmt.Errorf("%s: %w", op, err)
+	}
+	rev.PostID = id
+
+	rev, err = addRevision(ctx, tx, email, rev)
+	if err != nil {
+		return models.PostRevision{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.PostRevision{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return rev, nil
+}
+
+func addRevision(ctx context.Context, tx *sql.Tx, email string, rev models.PostRevision) (models.PostRevision, error) {
+	var owner string
+	var current int
+
+	// Блокировка строки поста упорядочивает конкурентные правки
+	row := tx.QueryRowContext(ctx, "SELECT email, revision FROM users_posts WHERE id = $1 FOR UPDATE", rev.PostID)
+	if err := row.Scan(&owner, &current); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.PostRevision{}, storage.ErrPostNotFound
+		}
+		return models.PostRevision{}, err
+	}
+	if owner != email {
+		return models.PostRevision{}, storage.ErrNotOwner
+	}
+
+	rev.Revision = current + 1
+	rev.Author = email
+
+	if err := insertRevision(ctx, tx, rev); err != nil {
+		return models.PostRevision{}, err
+	}
+
+	_, err := tx.ExecContext(ctx, `UPDATE users_posts SET
+		key = $2, filename = $3, content_type = $4, size = $5, checksum = $6,
+		enc_key_id = $7, enc_data_key = $8, revision = $9, updated_at = now()
+		WHERE id = $1`,
+		rev.PostID, rev.Key, rev.FileName, rev.ContentType, rev.Size, rev.Checksum,
+		rev.EncKeyID, rev.EncDataKey, rev.Revision)
+	if err != nil {
+		return models.PostRevision{}, err
+	}
+
+	return rev, nil
+}
+
+// RevisionsDB returns all revisions of the post, oldest first.
+func (s *Storage) RevisionsDB(ctx context.Context, id int64) ([]models.PostRevision, error) {
+	const op = "Storage/postgres/RevisionsDB"
+
+	revs := []models.PostRevision{}
+
+	err := s.db.SelectContext(ctx, &revs,
+		"SELECT "+revisionColumns+" FROM post_revisions WHERE post_id = $1 ORDER BY revision", id)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return revs, nil
+}
+
+func (s *Storage) RevisionDB(ctx context.Context, id int64, n int) (models.PostRevision, error) {
+	const op = "Storage/postgres/RevisionDB"
+
+	var rev models.PostRevision
+
+	err := s.db.GetContext(ctx, &rev,
+		"SELECT "+revisionColumns+" FROM post_revisions WHERE post_id = $1 AND revision = $2", id, n)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.PostRevision{}, fmt.Errorf("%s: %w", op, storage.ErrNoRevision)
+		}
+		return models.PostRevision{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return rev, nil
+}
+
+// RevisionKeysDB returns the distinct objects of all revisions of the posts.
+func (s *Storage) RevisionKeysDB(ctx context.Context, ids []int64) ([]models.PostRevision, error) {
+	const op = "Storage/postgres/RevisionKeysDB"
+
+	revs := []models.PostRevision{}
+
+	err := s.db.SelectContext(ctx, &revs,
+		"SELECT DISTINCT post_id, key FROM post_revisions WHERE post_id = ANY($1)", pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return revs, nil
+}
@@ -0,0 +1,97 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"github.com/maestro-milagro/Post_Service_PB/internal/models"
+	"time"
+)
+
+// RevokeTokenDB revokes the JWT with the given jti until it expires.
+func (s *Storage) RevokeTokenDB(ctx context.Context, token models.RevokedToken) error {
+	const op = "Storage/postgres/RevokeTokenDB"
+
+	_, err := s.db.ExecContext(ctx, `INSERT INTO revoked_tokens (jti, expires_at) VALUES ($1, $2)
+		ON CONFLICT (jti) DO UPDATE SET expires_at = greatest(revoked_tokens.expires_at, excluded.expires_at)`,
+		token.JTI, token.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// RevokeUserDB revokes the JWTs of the user issued before the cutoff together
+// with all of their API tokens. An earlier cutoff never replaces a later one.
+func (s *Storage) RevokeUserDB(ctx context.Context, user models.RevokedUser) error {
+	const op = "Storage/postgres/RevokeUserDB"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO revoked_users (email, revoked_before) VALUES ($1, $2)
+		ON CONFLICT (email) DO UPDATE SET revoked_before = greatest(revoked_users.revoked_before, excluded.revoked_before)`,
+		user.Email, user.RevokedBefore)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"UPDATE api_tokens SET revoked_at = now() WHERE email = $1 AND revoked_at IS NULL", user.Email)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// RevokedTokensDB lists the revoked JWTs that have not expired by now.
+func (s *Storage) RevokedTokensDB(ctx context.Context, now time.Time) ([]models.RevokedToken, error) {
+	const op = "Storage/postgres/RevokedTokensDB"
+
+	var tokens []models.RevokedToken
+
+	err := s.db.SelectContext(ctx, &tokens, "SELECT jti, expires_at FROM revoked_tokens WHERE expires_at > $1", now)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return tokens, nil
+}
+
+func (s *Storage) RevokedUsersDB(ctx context.Context) ([]models.RevokedUser, error) {
+	const op = "Storage/postgres/RevokedUsersDB"
+
+	var users []models.RevokedUser
+
+	err := s.db.SelectContext(ctx, &users, "SELECT email, revoked_before FROM revoked_users")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return users, nil
+}
+
+// DeleteExpiredRevocationsDB drops the revoked JWTs that have expired by now.
+func (s *Storage) DeleteExpiredRevocationsDB(ctx context.Context, now time.Time) (int64, error) {
+	const op = "Storage/postgres/DeleteExpiredRevocationsDB"
+
+	res, err := s.db.ExecContext(ctx, "DELETE FROM revoked_tokens WHERE expires_at <= $1", now)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return n, nil
+}
@@ -0,0 +1,111 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"github.com/maestro-milagro/Post_Service_PB/internal/models"
+	"github.com/maestro-milagro/Post_Service_PB/internal/storage"
+)
+
+const tokenColumns = "id, email, name, prefix, scopes, created_at, expires_at, last_used_at, revoked_at"
+
+// tokenRow scans the scopes array, which sqlx cannot put into a plain []string.
+type tokenRow struct {
+	models.APIToken
+	DBScopes pq.StringArray `db:"scopes"`
+}
+
+func (r tokenRow) token() models.APIToken {
+	t := r.APIToken
+	t.Scopes = []string(r.DBScopes)
+	return t
+}
+
+func (s *Storage) CreateAPITokenDB(ctx context.Context, token models.APIToken, hash []byte) (models.APIToken, error) {
+	const op = "Storage/postgres/CreateAPITokenDB"
+
+	var row tokenRow
+
+	err := s.db.GetContext(ctx, &row, `INSERT INTO api_tokens (email, name, prefix, hash, scopes, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING `+tokenColumns,
+		token.Email, token.Name, token.Prefix, hash, pq.Array(token.Scopes), token.ExpiresAt)
+	if err != nil {
+		return models.APIToken{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return row.token(), nil
+}
+
+// APITokensDB lists the tokens of the user that have not been revoked, newest first.
+func (s *Storage) APITokensDB(ctx context.Context, email string) ([]models.APIToken, error) {
+	const op = "Storage/postgres/APITokensDB"
+
+	var rows []tokenRow
+
+	err := s.db.SelectContext(ctx, &rows,
+		"SELECT "+tokenColumns+" FROM api_tokens WHERE email = $1 AND revoked_at IS NULL ORDER BY id DESC", email)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	tokens := make([]models.APIToken, 0, len(rows))
+	for _, row := range rows {
+		tokens = append(tokens, row.token())
+	}
+
+	return tokens, nil
+}
+
+func (s *Storage) APITokenByHashDB(ctx context.Context, hash []byte) (models.APIToken, error) {
+	const op = "Storage/postgres/APITokenByHashDB"
+
+	var row tokenRow
+
+	err := s.db.GetContext(ctx, &row, "SELECT "+tokenColumns+" FROM api_tokens WHERE hash = $1", hash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.APIToken{}, fmt.Errorf("%s: %w", op, storage.ErrNoToken)
+		}
+		return models.APIToken{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return row.token(), nil
+}
+
+// RevokeAPITokenDB revokes the user's token; revoking it again is not an error.
+func (s *Storage) RevokeAPITokenDB(ctx context.Context, email string, id int64) error {
+	const op = "Storage/postgres/RevokeAPITokenDB"
+
+	res, err := s.db.ExecContext(ctx,
+		"UPDATE api_tokens SET revoked_at = coalesce(revoked_at, now()) WHERE id = $1 AND email = $2", id, email)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrNoToken)
+	}
+
+	return nil
+}
+
+// TouchAPITokenDB records a use of the token. Writes are coalesced to one per
+// minute so a busy CI job does not update the row on every request.
+func (s *Storage) TouchAPITokenDB(ctx context.Context, id int64) error {
+	const op = "Storage/postgres/TouchAPITokenDB"
+
+	_, err := s.db.ExecContext(ctx, `UPDATE api_tokens SET last_used_at = now()
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < now() - interval '1 minute')`, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
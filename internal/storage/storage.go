@@ -5,5 +5,9 @@ import "errors"
 var (
 	ErrNoFollowers  = errors.New("no followers found")
 	ErrUserNotFound = errors.New("user not found")
-	ErrSubExist     = errors.New("subscription already exist")
+	ErrPostNotFound = errors.New("post not found")
+	ErrPostConsumed = errors.New("post already consumed")
+	ErrNotOwner     = errors.New("post belongs to another user")
+	ErrNoRevision   = errors.New("revision not found")
+	ErrNoToken      = errors.New("api token not found")
 )
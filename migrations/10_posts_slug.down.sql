@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS users_posts_slug_idx;
+
+ALTER TABLE users_posts DROP COLUMN IF EXISTS slug;
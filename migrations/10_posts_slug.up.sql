@@ -0,0 +1,8 @@
+ALTER TABLE users_posts ADD COLUMN IF NOT EXISTS slug TEXT;
+
+-- Существующим постам выдаём случайный slug из hex-символов, они входят в base62
+UPDATE users_posts SET slug = substr(replace(gen_random_uuid()::text, '-', ''), 1, 16) WHERE slug IS NULL;
+
+ALTER TABLE users_posts ALTER COLUMN slug SET NOT NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS users_posts_slug_idx ON users_posts (slug);
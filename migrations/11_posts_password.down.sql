@@ -0,0 +1 @@
+ALTER TABLE users_posts DROP COLUMN IF EXISTS password_hash;
@@ -0,0 +1 @@
+ALTER TABLE users_posts ADD COLUMN IF NOT EXISTS password_hash BYTEA;
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS users_posts_enc_key_id_idx;
+
+ALTER TABLE users_posts
+    DROP COLUMN IF EXISTS client_encrypted,
+    DROP COLUMN IF EXISTS enc_data_key,
+    DROP COLUMN IF EXISTS enc_key_id;
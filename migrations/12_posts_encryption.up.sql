@@ -0,0 +1,7 @@
+ALTER TABLE users_posts
+    ADD COLUMN IF NOT EXISTS enc_key_id TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS enc_data_key BYTEA,
+    ADD COLUMN IF NOT EXISTS client_encrypted BOOLEAN NOT NULL DEFAULT false;
+
+-- rotate-keys ищет посты, чей ключ данных обёрнут не активным мастер-ключом
+CREATE INDEX IF NOT EXISTS users_posts_enc_key_id_idx ON users_posts (enc_key_id, id) WHERE enc_key_id <> '';
@@ -0,0 +1,25 @@
+ALTER TABLE users_posts ADD COLUMN IF NOT EXISTS revision INTEGER NOT NULL DEFAULT 1;
+
+-- Ревизии неизменяемы: строка поста хранит копию текущей ревизии
+CREATE TABLE IF NOT EXISTS post_revisions
+(
+    post_id      INTEGER     NOT NULL REFERENCES users_posts (id) ON DELETE CASCADE,
+    revision     INTEGER     NOT NULL,
+    key          TEXT        NOT NULL,
+    filename     TEXT        NOT NULL DEFAULT '',
+    content_type TEXT        NOT NULL DEFAULT '',
+    size         BIGINT      NOT NULL DEFAULT 0,
+    checksum     TEXT        NOT NULL DEFAULT '',
+    enc_key_id   TEXT        NOT NULL DEFAULT '',
+    enc_data_key BYTEA,
+    author       TEXT        NOT NULL,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (post_id, revision)
+);
+
+CREATE INDEX IF NOT EXISTS post_revisions_enc_key_id_idx ON post_revisions (enc_key_id) WHERE enc_key_id <> '';
+
+INSERT INTO post_revisions (post_id, revision, key, filename, content_type, size, checksum, enc_key_id, enc_data_key, author, created_at)
+SELECT id, 1, key, filename, content_type, size, checksum, enc_key_id, enc_data_key, email, created_at
+FROM users_posts
+ON CONFLICT (post_id, revision) DO NOTHING;
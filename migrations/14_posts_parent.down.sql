@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS users_posts_parent_id_idx;
+
+ALTER TABLE users_posts DROP COLUMN IF EXISTS parent_id;
@@ -0,0 +1,5 @@
+-- Форк ссылается на исходный пост; удаление исходного форк не затрагивает
+ALTER TABLE users_posts
+    ADD COLUMN IF NOT EXISTS parent_id INTEGER REFERENCES users_posts (id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS users_posts_parent_id_idx ON users_posts (parent_id) WHERE parent_id IS NOT NULL;
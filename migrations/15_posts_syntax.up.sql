@@ -0,0 +1,2 @@
+-- Пустая строка: язык определяется по имени файла и содержимому
+ALTER TABLE users_posts ADD COLUMN IF NOT EXISTS syntax TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,16 @@
+-- Хранится только SHA-256 токена: сам токен показывается владельцу один раз при создании
+CREATE TABLE IF NOT EXISTS api_tokens
+(
+    id           SERIAL PRIMARY KEY,
+    email        TEXT        NOT NULL,
+    name         TEXT        NOT NULL,
+    prefix       TEXT        NOT NULL,
+    hash         BYTEA       NOT NULL UNIQUE,
+    scopes       TEXT[]      NOT NULL,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at   TIMESTAMPTZ,
+    last_used_at TIMESTAMPTZ,
+    revoked_at   TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS api_tokens_email_idx ON api_tokens (email);
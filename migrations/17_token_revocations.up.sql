@@ -0,0 +1,16 @@
+-- Отозванные JWT хранятся до истечения их срока, после этого они и так недействительны
+CREATE TABLE IF NOT EXISTS revoked_tokens
+(
+    jti        TEXT PRIMARY KEY,
+    expires_at TIMESTAMPTZ NOT NULL,
+    revoked_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS revoked_tokens_expires_at_idx ON revoked_tokens (expires_at);
+
+-- Все токены пользователя, выпущенные до revoked_before, считаются отозванными
+CREATE TABLE IF NOT EXISTS revoked_users
+(
+    email          TEXT PRIMARY KEY,
+    revoked_before TIMESTAMPTZ NOT NULL
+);
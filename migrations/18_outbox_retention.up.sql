@@ -0,0 +1,2 @@
+-- Доставленные события удаляет relay, когда истекает срок хранения
+CREATE INDEX IF NOT EXISTS outbox_delivered_idx ON outbox (delivered_at) WHERE delivered_at IS NOT NULL;
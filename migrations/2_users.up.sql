@@ -0,0 +1,7 @@
+-- Storage.WhoSubbedDB resolves authors through this table; it is filled by the auth service.
+CREATE TABLE IF NOT EXISTS users
+(
+    id        SERIAL PRIMARY KEY,
+    email     TEXT  NOT NULL UNIQUE,
+    pass_hash BYTEA NOT NULL
+);
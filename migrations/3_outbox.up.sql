@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS outbox
+(
+    id              BIGSERIAL PRIMARY KEY,
+    topic           TEXT        NOT NULL,
+    event_key       TEXT        NOT NULL,
+    payload         JSONB       NOT NULL,
+    attempts        INTEGER     NOT NULL DEFAULT 0,
+    last_error      TEXT,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    delivered_at    TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS outbox_pending_idx ON outbox (next_attempt_at) WHERE delivered_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS outbox_pending_key_idx ON outbox (event_key, id) WHERE delivered_at IS NULL;
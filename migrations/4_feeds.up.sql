@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS feeds
+(
+    id         BIGSERIAL PRIMARY KEY,
+    user_id    INTEGER     NOT NULL,
+    post_id    INTEGER     NOT NULL REFERENCES users_posts (id) ON DELETE CASCADE,
+    author     TEXT        NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (user_id, post_id)
+);
+
+CREATE INDEX IF NOT EXISTS feeds_user_idx ON feeds (user_id, id DESC);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS users_posts_created_at_idx;
+DROP INDEX IF EXISTS users_posts_email_id_idx;
+
+ALTER TABLE users_posts DROP COLUMN IF EXISTS created_at;
@@ -0,0 +1,4 @@
+ALTER TABLE users_posts ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT now();
+
+CREATE INDEX IF NOT EXISTS users_posts_email_id_idx ON users_posts (email, id);
+CREATE INDEX IF NOT EXISTS users_posts_created_at_idx ON users_posts (created_at);
@@ -0,0 +1,7 @@
+ALTER TABLE users_posts
+    DROP COLUMN IF EXISTS title,
+    DROP COLUMN IF EXISTS filename,
+    DROP COLUMN IF EXISTS content_type,
+    DROP COLUMN IF EXISTS size,
+    DROP COLUMN IF EXISTS checksum,
+    DROP COLUMN IF EXISTS updated_at;
@@ -0,0 +1,10 @@
+ALTER TABLE users_posts
+    ADD COLUMN IF NOT EXISTS title        TEXT        NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS filename     TEXT        NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS content_type TEXT        NOT NULL DEFAULT 'application/octet-stream',
+    ADD COLUMN IF NOT EXISTS size         BIGINT      NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS checksum     TEXT        NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS updated_at   TIMESTAMPTZ NOT NULL DEFAULT now();
+
+-- Older posts used the uploaded filename as the key
+UPDATE users_posts SET filename = key, title = key, updated_at = created_at WHERE filename = '';
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS users_posts_expires_at_idx;
+
+ALTER TABLE users_posts DROP COLUMN IF EXISTS expires_at;
@@ -0,0 +1,3 @@
+ALTER TABLE users_posts ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS users_posts_expires_at_idx ON users_posts (expires_at) WHERE expires_at IS NOT NULL;
@@ -0,0 +1,3 @@
+ALTER TABLE users_posts
+    DROP COLUMN IF EXISTS burn_after_read,
+    DROP COLUMN IF EXISTS consumed_at;
@@ -0,0 +1,3 @@
+ALTER TABLE users_posts
+    ADD COLUMN IF NOT EXISTS burn_after_read BOOLEAN NOT NULL DEFAULT false,
+    ADD COLUMN IF NOT EXISTS consumed_at     TIMESTAMPTZ;
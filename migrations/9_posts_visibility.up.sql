@@ -0,0 +1,3 @@
+ALTER TABLE users_posts
+    ADD COLUMN IF NOT EXISTS visibility TEXT NOT NULL DEFAULT 'public'
+        CHECK (visibility IN ('public', 'unlisted', 'followers', 'private'));